@@ -0,0 +1,411 @@
+// Command roamsim runs roaming scenarios against the chaincode without a
+// Fabric network. Each scenario file (YAML or JSON) seeds the ledger, then
+// executes a sequence of invokes and queries on a MockStub with a scenario
+// controlled clock, checking the results it is told to expect:
+//
+//	name: roaming call is charged at the partner tariff
+//	start: 2017-01-02T10:00:00Z
+//	seed: {"environment": "development", "fixtures": true}
+//	steps:
+//	  - invoke: discoverRP
+//	    args: [rs1, XYZ, BARCELONA, "41.3851", "2.1734"]
+//	  - invoke: CallOut
+//	    args: [rs1, "349091234567"]
+//	    save: {call: sessionid}
+//	  - invoke: CallEnd
+//	    args: [rs1, $call]
+//	    advance: 3m
+//	  - query: queryMSISDN
+//	    args: [rs1]
+//	    expect: {duration: 3, charges: 15}
+//
+// Usage:
+//
+//	roamsim [-q] [-v] scenario.yaml...
+//
+// roamsim prints a transcript of every step and exits non-zero if any
+// expectation fails. The chaincode's own logging is hidden unless -v is set.
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amanrubal/ChaincodeUpload/roaming"
+	"github.com/op/go-logging"
+	"gopkg.in/yaml.v2"
+)
+
+// scenario is one scenario file. Ledger holds raw records, by ledger key,
+// written before Init as if left by an older chaincode.
+type scenario struct {
+	Name   string                     `json:"name"`
+	Start  time.Time                  `json:"start"`
+	Seed   json.RawMessage            `json:"seed"`
+	Ledger map[string]json.RawMessage `json:"ledger"`
+	Steps  []step                     `json:"steps"`
+}
+
+// step is one invoke or query. Advance moves the clock before the step
+// runs; Caller, MSP and Role are the operator, mspid and role attributes of
+// the invoking certificate and Metadata is passed as the transaction
+// metadata. Save keeps fields of the
+// result, such as a session ID, in variables later args refer to as $name.
+// Expect and Save name a field of a nested object or list by its path, e.g.
+// result.nonce or items.0.operator. Sign
+// signs a message as a subscriber's device would, with the Ed25519 key of
+// a hex seed, and keeps the base64 signature in a variable.
+type step struct {
+	Invoke      string                 `json:"invoke"`
+	Query       string                 `json:"query"`
+	Args        []string               `json:"args"`
+	Caller      string                 `json:"caller"`
+	MSP         string                 `json:"msp"`
+	Role        string                 `json:"role"`
+	Metadata    json.RawMessage        `json:"metadata"`
+	Advance     string                 `json:"advance"`
+	Expect      map[string]interface{} `json:"expect"`
+	ExpectError string                 `json:"expecterror"`
+	ExpectEvent string                 `json:"expectevent"`
+	Save        map[string]string      `json:"save"`
+	Sign        map[string]signing     `json:"sign"`
+}
+
+type signing struct {
+	Seed    string `json:"seed"`
+	Message string `json:"message"`
+}
+
+// out receives the transcript. os.Stdout is pointed elsewhere while the
+// chaincode runs so its logging does not interleave with the transcript.
+var out io.Writer = os.Stdout
+
+func main() {
+	quiet := flag.Bool("q", false, "only print failures and the summary")
+	verbose := flag.Bool("v", false, "show chaincode logging")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: roamsim [-q] [-v] scenario.yaml...")
+		os.Exit(2)
+	}
+	if !*verbose {
+		devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		os.Stdout = devNull
+		//The MockStub logs every state access at debug level
+		logging.SetLevel(logging.WARNING, "mock")
+	}
+
+	failed := 0
+	for _, path := range flag.Args() {
+		sc, err := loadScenario(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+			failed++
+			continue
+		}
+		if !run(sc, *quiet) {
+			failed++
+		}
+	}
+	fmt.Fprintf(out, "\n%d scenario(s), %d failed\n", flag.NArg(), failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// loadScenario reads a YAML or JSON scenario. YAML is converted to JSON
+// first so both formats share the json field names above.
+func loadScenario(path string) (scenario, error) {
+	var sc scenario
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return sc, err
+	}
+	if ext := filepath.Ext(path); ext == ".yaml" || ext == ".yml" {
+		var doc interface{}
+		if err = yaml.Unmarshal(raw, &doc); err != nil {
+			return sc, err
+		}
+		if raw, err = json.Marshal(jsonValue(doc)); err != nil {
+			return sc, err
+		}
+	}
+	if err = json.Unmarshal(raw, &sc); err != nil {
+		return sc, err
+	}
+	if sc.Name == "" {
+		sc.Name = filepath.Base(path)
+	}
+	if sc.Start.IsZero() {
+		sc.Start = time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return sc, nil
+}
+
+// jsonValue turns the map[interface{}]interface{} values produced by the
+// YAML decoder into values encoding/json can marshal
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			m[fmt.Sprint(k)] = jsonValue(val)
+		}
+		return m
+	case []interface{}:
+		for i, val := range v {
+			v[i] = jsonValue(val)
+		}
+	}
+	return v
+}
+
+// run executes sc on a fresh ledger and reports whether every step passed
+func run(sc scenario, quiet bool) bool {
+	fmt.Fprintf(out, "=== %s\n", sc.Name)
+	cc := new(roaming.SimpleChaincode)
+	stub := newSimStub(cc, sc.Start)
+
+	if len(sc.Ledger) > 0 {
+		stub.MockTransactionStart("ledger")
+		for key, value := range sc.Ledger {
+			stub.PutState(key, value)
+		}
+		stub.MockTransactionEnd("ledger")
+	}
+
+	var initArgs []string
+	if len(sc.Seed) > 0 {
+		initArgs = []string{string(sc.Seed)}
+	}
+	stub.MockTransactionStart("init")
+	_, err := cc.Init(stub, "init", initArgs)
+	stub.MockTransactionEnd("init")
+	if err != nil {
+		fmt.Fprintf(out, "FAIL init: %s\n", err)
+		return false
+	}
+
+	ok := true
+	vars := map[string]string{}
+	for i, st := range sc.Steps {
+		if st.Advance != "" {
+			d, err := time.ParseDuration(st.Advance)
+			if err != nil {
+				fmt.Fprintf(out, "FAIL step %d: bad advance %q: %s\n", i+1, st.Advance, err)
+				return false
+			}
+			stub.clock = stub.clock.Add(d)
+		}
+		stub.caller = st.Caller
+		stub.msp = st.MSP
+		stub.role = st.Role
+		stub.metadata = []byte(st.Metadata)
+		stub.event = nil
+		for name, sg := range st.Sign {
+			sig, err := sign(sg.Seed, substitute(sg.Message, vars))
+			if err != nil {
+				fmt.Fprintf(out, "FAIL step %d: cannot sign %s: %s\n", i+1, name, err)
+				return false
+			}
+			vars[name] = sig
+		}
+		args := make([]string, len(st.Args))
+		for j, arg := range st.Args {
+			args[j] = substitute(arg, vars)
+		}
+
+		var result []byte
+		function := st.Invoke
+		if st.Invoke != "" {
+			txid := fmt.Sprintf("tx%d", i+1)
+			stub.MockTransactionStart(txid)
+			result, err = cc.Invoke(stub, st.Invoke, args)
+			stub.MockTransactionEnd(txid)
+		} else {
+			function = st.Query
+			result, err = cc.Query(stub, st.Query, args)
+		}
+
+		line := fmt.Sprintf("[%s] %s(%s)", stub.clock.Format("2006-01-02 15:04:05"), function, strings.Join(args, ", "))
+		if st.Caller != "" {
+			line = st.Caller + " " + line
+		}
+		problems := check(st, result, err, stub.event)
+		if !quiet || len(problems) > 0 {
+			fmt.Fprintln(out, transcript(line, result, err, stub.event))
+			for _, p := range problems {
+				fmt.Fprintf(out, "    FAIL %s\n", p)
+			}
+		}
+		if len(problems) > 0 {
+			ok = false
+		}
+		problems = save(st.Save, result, vars)
+		for _, p := range problems {
+			fmt.Fprintf(out, "    FAIL %s\n", p)
+			ok = false
+		}
+	}
+	if ok {
+		fmt.Fprintln(out, "--- PASS")
+	} else {
+		fmt.Fprintln(out, "--- FAIL")
+	}
+	return ok
+}
+
+func transcript(line string, result []byte, err error, event *simEvent) string {
+	switch {
+	case err != nil:
+		line += " -> error: " + err.Error()
+	case len(result) > 0:
+		line += " -> " + string(result)
+	default:
+		line += " -> ok"
+	}
+	if event != nil {
+		line += fmt.Sprintf("\n    event %s %s", event.Name, event.Payload)
+	}
+	return line
+}
+
+// substitute replaces a $name arg with the variable it names
+func substitute(arg string, vars map[string]string) string {
+	if v, found := vars[strings.TrimPrefix(arg, "$")]; found && strings.HasPrefix(arg, "$") {
+		return v
+	}
+	return arg
+}
+
+// sign returns the base64 Ed25519 signature of message by the key of seed
+func sign(seed string, message string) (string, error) {
+	b, err := hex.DecodeString(seed)
+	if err != nil || len(b) != ed25519.SeedSize {
+		return "", fmt.Errorf("seed must be %d hex encoded bytes", ed25519.SeedSize)
+	}
+	sig := ed25519.Sign(ed25519.NewKeyFromSeed(b), []byte(message))
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// save copies the requested result fields into vars
+func save(fields map[string]string, result []byte, vars map[string]string) []string {
+	if len(fields) == 0 {
+		return nil
+	}
+	actual, err := resultObject(result)
+	if err != nil {
+		return []string{"cannot save from a result that is not a JSON object or list"}
+	}
+	var problems []string
+	for name, field := range fields {
+		v, present := lookup(actual, field)
+		if !present {
+			problems = append(problems, fmt.Sprintf("cannot save %s: %s missing from result", name, field))
+			continue
+		}
+		vars[name] = fmt.Sprint(v)
+	}
+	return problems
+}
+
+// check compares the outcome of a step with its expectations
+func check(st step, result []byte, err error, event *simEvent) []string {
+	var problems []string
+	if st.ExpectError != "" {
+		if err == nil || !strings.Contains(err.Error(), st.ExpectError) {
+			problems = append(problems, fmt.Sprintf("expected error containing %q, got %v", st.ExpectError, err))
+		}
+		return problems
+	}
+	if err != nil {
+		return append(problems, "unexpected error: "+err.Error())
+	}
+	if st.ExpectEvent != "" && (event == nil || event.Name != st.ExpectEvent) {
+		got := "none"
+		if event != nil {
+			got = event.Name
+		}
+		problems = append(problems, fmt.Sprintf("expected event %s, got %s", st.ExpectEvent, got))
+	}
+	if len(st.Expect) == 0 {
+		return problems
+	}
+	actual, err := resultObject(result)
+	if err != nil {
+		return append(problems, err.Error())
+	}
+	for field, want := range st.Expect {
+		got, present := lookup(actual, field)
+		if !present {
+			problems = append(problems, fmt.Sprintf("%s missing from result", field))
+		} else if !equal(want, got) {
+			problems = append(problems, fmt.Sprintf("%s = %v, expected %v", field, got, want))
+		}
+	}
+	return problems
+}
+
+// resultObject decodes a result for checking. A list is checked as an
+// object with its length as count and its elements as items.
+func resultObject(result []byte) (map[string]interface{}, error) {
+	var list []interface{}
+	if err := json.Unmarshal(result, &list); err == nil {
+		return map[string]interface{}{"count": float64(len(list)), "items": list}, nil
+	}
+	var actual map[string]interface{}
+	if err := json.Unmarshal(result, &actual); err != nil {
+		return nil, errors.New("result is not a JSON object or list")
+	}
+	return actual, nil
+}
+
+// lookup finds the field at a dotted path in a decoded result, indexing
+// lists by position
+func lookup(v interface{}, path string) (interface{}, bool) {
+	for _, part := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			var present bool
+			if v, present = node[part]; !present {
+				return nil, false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			v = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// equal compares numbers within a cent and everything else by value
+func equal(want, got interface{}) bool {
+	w, wok := want.(float64)
+	g, gok := got.(float64)
+	if wok && gok {
+		return math.Abs(w-g) < 0.005
+	}
+	return fmt.Sprint(want) == fmt.Sprint(got)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/op/go-logging"
+)
+
+// TestScenarios runs every scenario shipped with the simulator and fails on
+// any step whose expectations are not met
+func TestScenarios(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("scenarios", "*.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no scenarios found")
+	}
+	//As in main, keep chaincode logging out of the transcript
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+	stdout, saved := os.Stdout, out
+	os.Stdout = devNull
+	defer func() { os.Stdout, out = stdout, saved }()
+	logging.SetLevel(logging.WARNING, "mock")
+
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			sc, err := loadScenario(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var transcript bytes.Buffer
+			out = &transcript
+			if !run(sc, true) {
+				t.Errorf("scenario failed:\n%s", transcript.String())
+			}
+		})
+	}
+}
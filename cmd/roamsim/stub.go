@@ -0,0 +1,53 @@
+package main
+
+import (
+	"time"
+
+	"github.com/amanrubal/ChaincodeUpload/internal/shimtest"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// simStub is the MockStub with the parts a scenario controls layered on top:
+// the transaction clock, the invoking certificate's attributes and request
+// metadata. It also keeps the chaincode event of the last transaction for
+// the transcript. Range scans are shimtest's, which keep to their bounds.
+type simStub struct {
+	*shimtest.Stub
+	clock    time.Time
+	caller   string
+	msp      string
+	role     string
+	metadata []byte
+	event    *simEvent
+}
+
+type simEvent struct {
+	Name    string
+	Payload []byte
+}
+
+func newSimStub(cc shim.Chaincode, start time.Time) *simStub {
+	return &simStub{Stub: shimtest.NewStub("roamsim", cc), clock: start}
+}
+
+func (s *simStub) GetTxTimestamp() (*timestamp.Timestamp, error) {
+	return &timestamp.Timestamp{Seconds: s.clock.Unix(), Nanos: int32(s.clock.Nanosecond())}, nil
+}
+
+func (s *simStub) ReadCertAttribute(attributeName string) ([]byte, error) {
+	attrs := map[string]string{"operator": s.caller, "mspid": s.msp, "role": s.role}
+	if v := attrs[attributeName]; v != "" {
+		return []byte(v), nil
+	}
+	return nil, nil
+}
+
+func (s *simStub) GetCallerMetadata() ([]byte, error) {
+	return s.metadata, nil
+}
+
+func (s *simStub) SetEvent(name string, payload []byte) error {
+	s.event = &simEvent{name, payload}
+	return nil
+}
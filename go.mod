@@ -0,0 +1,33 @@
+module github.com/amanrubal/ChaincodeUpload
+
+go 1.21
+
+require (
+	github.com/golang/protobuf v1.3.5
+	github.com/hyperledger/fabric v0.6.1-preview
+	github.com/op/go-logging v0.0.0-20160315200505-970db520ece7
+	gopkg.in/yaml.v2 v2.4.0
+)
+
+require (
+	github.com/BurntSushi/toml v0.3.1 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/google/go-cmp v0.5.5 // indirect
+	github.com/kr/pretty v0.2.0 // indirect
+	github.com/kr/text v0.2.0 // indirect
+	github.com/looplab/fsm v0.1.0 // indirect
+	github.com/magiconair/properties v1.7.6 // indirect
+	github.com/mitchellh/mapstructure v1.0.0 // indirect
+	github.com/spf13/cast v0.0.0-20150508191742-4d07383ffe94 // indirect
+	github.com/spf13/jwalterweatherman v0.0.0-20141219030609-3d60171a6431 // indirect
+	github.com/spf13/pflag v1.0.5 // indirect
+	github.com/spf13/viper v0.0.0-20150530192845-be5ff3e4840c // indirect
+	github.com/stretchr/testify v1.6.0 // indirect
+	golang.org/x/crypto v0.10.0 // indirect
+	golang.org/x/net v0.11.0 // indirect
+	golang.org/x/sys v0.10.0 // indirect
+	golang.org/x/text v0.13.0 // indirect
+	google.golang.org/genproto v0.0.0-20190819201941-24fa4b261c55 // indirect
+	google.golang.org/grpc v1.33.1 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
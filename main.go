@@ -0,0 +1,18 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/amanrubal/ChaincodeUpload/roaming"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+//MAIN FUNCTION
+func main() {
+	err := shim.Start(new(roaming.SimpleChaincode))
+
+	fmt.Printf("IN MAIN of TelcoChaincode")
+	if err != nil {
+		fmt.Printf("Error starting Simple chaincode: %s", err)
+	}
+}
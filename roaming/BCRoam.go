@@ -0,0 +1,1066 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+  http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License .
+*/
+
+package roaming
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+
+
+var rsmap map[string]string
+
+
+// SimpleChaincode example simple Chaincode implementation
+type SimpleChaincode struct {
+}
+
+// This is our structure for the broadcaster creating bulk inventory
+
+type rsDetailBlock struct {
+	schemaStamp
+	PublicKey   string    `json:"publickey"`
+	MSISDN      string    `json:"msisdn"`
+	Name        string    `json:"name"`
+	Address     string    `json:"address"`
+	HO          string    `json:"ho"`
+	RP          string    `json:"rp"`
+	Roaming     string    `json:"roaming"`
+	Location    string    `json:"location"`
+	Lat    	    string    `json:"lat"`
+	Long        string    `json:"long"`
+	RateType    string    `json:"ratetype"`
+	Action      string    `json:"action"`
+	TransType   string    `json:"transtype"`
+	Destination string    `json:"destination"`
+	Duration    float64    `json:"duration"`
+	Charges     float64    `json:"charges"`
+	Flag        string    `json:"flag"`
+	Time        time.Time `json:"time"`
+	Status        string         `json:"status"`
+	StatusReason  string         `json:"statusreason"`
+	StatusHistory []statusChange `json:"statushistory"`
+	Encrypted     []string       `json:"encrypted"`
+	State         string         `json:"state"`
+	Plan          string         `json:"plan"`
+	AuthKey       string         `json:"authkey"`
+	IMSI          string         `json:"imsi"`
+	ICCID         string         `json:"iccid"`
+	SIMState      string         `json:"simstate"`
+	Position      *geoPoint      `json:"position,omitempty"`
+}
+
+//This is a helper structure to point to allPeers
+type AllPeers struct {
+	PeerName []string `json:"peerName"`
+}
+
+//For Debugging
+func showArgs(args []string) {
+
+	for i := 0; i < len(args); i++ {
+		fmt.Printf("\n %d) : [%s]", i, args[i])
+	}
+	fmt.Printf("\n")
+}
+
+// Init function
+// args[0] is an optional seed document, see seedDocument. Seeding is skipped
+// when the ledger already holds a config, so redeploying is safe.
+func (t *SimpleChaincode) Init(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
+
+	fmt.Println("Launching Init Function")
+	rsmap = make(map[string]string)
+
+	doc, err := parseSeed(args)
+	if err != nil {
+		return nil, err
+	}
+	if err = t.seed(stub, doc); err != nil {
+		fmt.Println("Error - could not seed ledger: ", err)
+		return nil, err
+	}
+
+	fmt.Println("Init Function Complete")
+	return nil, nil
+}
+
+//Reload the demo inventory, only outside production
+func (t *SimpleChaincode) resetInventory(stub shim.ChaincodeStubInterface) ([]byte, error) {
+
+	fmt.Println("resetting Inventory")
+	if err := t.loadFixtures(stub); err != nil {
+		fmt.Println("Error - could not reset inventory: ", err)
+		return nil, err
+	}
+	fmt.Println("Reset Function Complete")
+	return newInvokeResponse(stub, "resetInventory", nil).marshal()
+
+}
+
+//Invoke function
+
+func (t *SimpleChaincode) Invoke(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
+	fmt.Printf("Invoke called, determining function :%v", function)
+
+	showArgs(args)
+	var key, sp, loc, lat,long,msisdn,name,address,ho, destmsisdn string
+
+	// Handle different functions
+	if function == "discoverRP" {
+		fmt.Printf("Function is discoverRP")
+		key = t.resolveKey(stub, args[0])
+		sp = args[1]
+		loc = args[2]
+		lat = args[3]
+		long = args[4]
+		return t.discoverRP(stub, key, sp, loc,lat,long)
+	} else if function == "authentication" {
+		fmt.Printf("Function is authentication")
+		key = t.resolveKey(stub, args[0])
+		//nonce and signature answering authChallenge
+		var nonce, signature string
+		if len(args) > 2 {
+			nonce = args[1]
+			signature = args[2]
+		}
+		return t.authentication(stub, key, nonce, signature)
+	} else if function == "authChallenge" {
+		fmt.Printf("Function is authChallenge")
+		if len(args) < 1 {
+			return nil, errors.New("Incorrect number of arguments. Expecting key")
+		}
+		return t.authChallenge(stub, t.resolveKey(stub, args[0]))
+	} else if function == "enrolAuthKey" {
+		fmt.Printf("Function is enrolAuthKey")
+		if len(args) < 2 {
+			return nil, errors.New("Incorrect number of arguments. Expecting key and public key")
+		}
+		return t.enrolAuthKey(stub, args[0], args[1])
+	} else if function == "assignSIM" {
+		fmt.Printf("Function is assignSIM")
+		if len(args) < 3 {
+			return nil, errors.New("Incorrect number of arguments. Expecting key, IMSI and ICCID")
+		}
+		return t.assignSIM(stub, args[0], args[1], args[2])
+	} else if function == "setSIMState" {
+		fmt.Printf("Function is setSIMState")
+		if len(args) < 2 {
+			return nil, errors.New("Incorrect number of arguments. Expecting key and SIM state")
+		}
+		return t.setSIMState(stub, args[0], args[1])
+	} else if function == "updateRates" {
+		fmt.Printf("Function is updateRates")
+		key = args[0]
+		return t.updateRates(stub, key)
+	} else if function == "CallOut" {
+		fmt.Printf("Function is CallOut")
+		key = args[0]
+		destmsisdn = args[1]
+		return t.CallOut(stub, key, destmsisdn)
+	} else if function == "CallIn" {
+		fmt.Printf("Function is CallIn")
+		if len(args) < 2 {
+			return nil, errors.New("Incorrect number of arguments. Expecting key and caller MSISDN")
+		}
+		return t.CallIn(stub, args[0], args[1])
+	} else if function == "DataStart" {
+		fmt.Printf("Function is DataStart")
+		key = args[0]
+		return t.DataStart(stub, key)
+	} else if function == "SMSOut" {
+		fmt.Printf("Function is SMSOut")
+		key = args[0]
+		destmsisdn = args[1]
+		return t.SMSOut(stub, key, destmsisdn)
+	} else if function == "CallEnd" {
+		fmt.Printf("Function is CallEnd")
+		if len(args) < 2 {
+			return nil, errors.New("Incorrect number of arguments. Expecting key, session ID and optional data volume")
+		}
+		key = args[0]
+		volume := 0.0
+		if len(args) > 2 {
+			var err error
+			if volume, err = strconv.ParseFloat(args[2], 64); err != nil {
+				return nil, errors.New("data volume must be a number")
+			}
+		}
+		return t.CallEnd(stub, key, args[1], volume)
+	} else if function == "CallPay" {
+		fmt.Printf("Function is CallPay")
+		if len(args) < 2 {
+			return nil, errors.New("Incorrect number of arguments. Expecting key and session ID")
+		}
+		key = args[0]
+		return t.CallPay(stub, key, args[1])
+	} else if function == "Overage" {
+		fmt.Printf("Function is Overage")
+		key = args[0]
+		return t.Overage(stub, key)
+	} else if function == "resetInventory" {
+		fmt.Printf("Function is resetInventory")
+		return t.resetInventory(stub)
+	}else if function == "enterData" {
+		fmt.Printf("Function is enterData")
+		key =args[0]
+		msisdn =args[1]
+		name =args[2]
+		address =args[3]
+		ho =args[4]
+		lat =args[5]
+		long =args[6]
+		//Optional public key of the subscriber's SIM or device
+		authKey := ""
+		if len(args) > 7 {
+			authKey = args[7]
+		}
+		return t.enterData(stub,key,msisdn,name,address,ho,lat,long,authKey)
+	} else if function == "setSessionLimit" {
+		fmt.Printf("Function is setSessionLimit")
+		if len(args) < 1 {
+			return nil, errors.New("Incorrect number of arguments. Expecting limit")
+		}
+		return t.setSessionLimit(stub, args[0])
+	} else if function == "setUnenrolledAuth" {
+		fmt.Printf("Function is setUnenrolledAuth")
+		if len(args) < 1 {
+			return nil, errors.New("Incorrect number of arguments. Expecting true or false")
+		}
+		return t.setUnenrolledAuth(stub, args[0])
+	} else if function == "migrateSchema" {
+		fmt.Printf("Function is migrateSchema")
+		return t.migrateSchema(stub, args)
+	} else if function == "setSteeringPolicy" {
+		fmt.Printf("Function is setSteeringPolicy")
+		return t.setSteeringPolicy(stub, args)
+	} else if function == "setAlertCaps" {
+		fmt.Printf("Function is setAlertCaps")
+		return t.setAlertCaps(stub, args)
+	} else if function == "setZone" {
+		fmt.Printf("Function is setZone")
+		return t.setZone(stub, args)
+	} else if function == "setPlan" {
+		fmt.Printf("Function is setPlan")
+		return t.setPlan(stub, args)
+	} else if function == "onboardOperator" {
+		fmt.Printf("Function is onboardOperator")
+		return t.onboardOperator(stub, args)
+	} else if function == "assignPlan" {
+		fmt.Printf("Function is assignPlan")
+		if len(args) < 2 {
+			return nil, errors.New("Incorrect number of arguments. Expecting key and plan")
+		}
+		return t.assignPlan(stub, args[0], args[1])
+	} else if function == "bulkEnterData" {
+		fmt.Printf("Function is bulkEnterData")
+		if len(args) < 2 {
+			return nil, errors.New("Incorrect number of arguments. Expecting format and payload")
+		}
+		return t.bulkEnterData(stub, args[0], args[1])
+	} else if function == "suspendSubscriber" || function == "barSubscriber" ||
+		function == "reactivateSubscriber" || function == "terminateSubscriber" {
+		fmt.Printf("Function is %s", function)
+		if len(args) < 2 {
+			return nil, errors.New("Incorrect number of arguments. Expecting key and reason")
+		}
+		key = args[0]
+		switch function {
+		case "suspendSubscriber":
+			return t.suspendSubscriber(stub, key, args[1])
+		case "barSubscriber":
+			return t.barSubscriber(stub, key, args[1])
+		case "reactivateSubscriber":
+			return t.reactivateSubscriber(stub, key, args[1])
+		default:
+			return t.terminateSubscriber(stub, key, args[1])
+		}
+	} else if function == "requestPort" {
+		fmt.Printf("Function is requestPort")
+		if len(args) < 2 {
+			return nil, errors.New("Incorrect number of arguments. Expecting key and MSISDN")
+		}
+		return t.requestPort(stub, args[0], args[1])
+	} else if function == "approvePort" {
+		fmt.Printf("Function is approvePort")
+		if len(args) < 1 {
+			return nil, errors.New("Incorrect number of arguments. Expecting MSISDN")
+		}
+		return t.answerPort(stub, args[0], true, "")
+	} else if function == "rejectPort" {
+		fmt.Printf("Function is rejectPort")
+		if len(args) < 2 {
+			return nil, errors.New("Incorrect number of arguments. Expecting MSISDN and reason")
+		}
+		return t.answerPort(stub, args[0], false, args[1])
+	} else if function == "completePort" {
+		fmt.Printf("Function is completePort")
+		if len(args) < 1 {
+			return nil, errors.New("Incorrect number of arguments. Expecting MSISDN")
+		}
+		return t.completePort(stub, args[0])
+	}
+	return nil, errors.New("Received unknown function invocation")
+}
+
+//QUERY FUNCTION
+func (t *SimpleChaincode) Query(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
+	fmt.Printf("======== Query called, determining function")
+
+	showArgs(args)
+
+	if function == "queryMSISDN" {
+		fmt.Printf("Function is queryPeers")
+		return t.queryMSISDN(stub, args)
+	} else if function == "queryByMSISDN" {
+		fmt.Printf("Function is queryByMSISDN")
+		return t.queryByMSISDN(stub, args)
+	} else if function == "querySessions" {
+		fmt.Printf("Function is querySessions")
+		return t.querySessions(stub, args)
+	} else if function == "listSubscribers" {
+		fmt.Printf("Function is listSubscribers")
+		return t.listSubscribers(stub, args)
+	} else if function == "queryPorting" {
+		fmt.Printf("Function is queryPorting")
+		return t.queryPorting(stub, args)
+	} else if function == "estimateCharge" {
+		fmt.Printf("Function is estimateCharge")
+		return t.estimateCharge(stub, args)
+	} else if function == "recommendPartners" {
+		fmt.Printf("Function is recommendPartners")
+		return t.recommendPartners(stub, args)
+	} else if function == "querySteering" {
+		fmt.Printf("Function is querySteering")
+		return t.querySteering(stub, args)
+	} else if function == "queryRoamingUsage" {
+		fmt.Printf("Function is queryRoamingUsage")
+		return t.queryRoamingUsage(stub, args)
+	} else if function == "queryAllowance" {
+		fmt.Printf("Function is queryAllowance")
+		return t.queryAllowance(stub, args)
+	} else if function == "queryOperator" {
+		fmt.Printf("Function is queryOperator")
+		return t.queryOperator(stub, args)
+	} else if function == "queryByIMSI" {
+		fmt.Printf("Function is queryByIMSI")
+		return t.queryByIMSI(stub, args)
+	} else if function == "queryLocations" {
+		fmt.Printf("Function is queryLocations")
+		if len(args) > 0 {
+			args[0] = t.resolveKey(stub, args[0])
+		}
+		return t.queryLocations(stub, args)
+	} else if function == "queryArea" {
+		fmt.Printf("Function is queryArea")
+		return t.queryArea(stub, args)
+	} else {
+		fmt.Printf("Invalid Function!")
+	}
+
+	return nil, nil
+}
+
+////////////////////////////////////////////////////
+
+//Redirect FUNCTIONS
+
+//Query MSISDN in our network
+func (t *SimpleChaincode) queryMSISDN(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	fmt.Println("queryMSISDN called")
+	var key string
+	key = args[0]
+	fmt.Println("Key: ", key)
+	bytes, _ := stub.GetState(key)
+	fmt.Println(string(bytes))
+	fmt.Printf("%x", bytes)
+	//Decrypt for callers holding the field keys
+	keys, err := readFieldKeys(stub)
+	if err != nil {
+		return nil, err
+	}
+	if bytes == nil {
+		return nil, nil
+	}
+	//Older records are returned in the current layout
+	rs, err := decodeSubscriber(bytes)
+	if err != nil {
+		return nil, err
+	}
+	if keys != nil {
+		if err = openSubscriber(&rs, keys); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(rs)
+}
+
+func (t *SimpleChaincode) enterData(stub shim.ChaincodeStubInterface, key string,msisdn string,name string,address string,ho string,lat string,long string,authKey string) ([]byte, error) {
+
+	//Optional field encryption, keys come with the request metadata
+	keys, err := readFieldKeys(stub)
+	if err != nil {
+		return nil, err
+	}
+	if err = t.checkReentry(stub, key); err != nil {
+		return nil, err
+	}
+	rsDetailObj, err := t.newSubscriber(stub, keys, key, msisdn, name, address, ho, lat, long)
+	if err != nil {
+		return nil, err
+	}
+	if authKey != "" {
+		//As enrolAuthKey, only the home operator enrols device keys
+		caller, err := callerOperator(stub)
+		if err != nil {
+			return nil, err
+		}
+		if caller != ho {
+			return nil, fmt.Errorf("only home operator %s may enrol keys for %s", ho, key)
+		}
+		if _, err = parseAuthKey(authKey); err != nil {
+			return nil, err
+		}
+		rsDetailObj.AuthKey = authKey
+	}
+
+	fmt.Println(rsDetailObj)
+	bytes, _ := json.Marshal(rsDetailObj)
+	fmt.Println(string(bytes))
+
+	_, err2 := t.putMSIDN(stub, rsDetailObj, rsDetailObj.PublicKey)
+	if err2 != nil {
+		fmt.Println("Error - could not Marshall in rsDetailObj")
+		return nil, err2
+	} else {
+		fmt.Println("Success -  works")
+	}
+	if err = t.recordLocation(stub, rsDetailObj, locationEntered); err != nil {
+		return nil, err
+	}
+
+	return newInvokeResponse(stub, "enterData", &rsDetailObj).marshal()
+}
+
+// checkReentry lets only the home operator of an existing subscriber enter
+// it again; anyone may enter a new key
+func (t *SimpleChaincode) checkReentry(stub shim.ChaincodeStubInterface, key string) error {
+	existing, err := t.getSubscriber(stub, key)
+	if err != nil {
+		return nil
+	}
+	caller, err := callerOperator(stub)
+	if err != nil {
+		return err
+	}
+	if caller != existing.HO {
+		return fmt.Errorf("only home operator %s may re-enter %s", existing.HO, key)
+	}
+	return nil
+}
+
+//newSubscriber: To validate enterData input and build the record to put on the ledger.
+//A subscriber entered again keeps its lifecycle status and history, which
+//only changeStatus moves, its enrolled device key, its plan and its SIM.
+func (t *SimpleChaincode) newSubscriber(stub shim.ChaincodeStubInterface, keys *fieldKeys, key string, msisdn string, name string, address string, ho string, lat string, long string) (rsDetailBlock, error) {
+
+	var rsDetailObj rsDetailBlock
+	if key == "" || msisdn == "" || ho == "" {
+		return rsDetailObj, errors.New("key, msisdn and ho are required")
+	}
+	if err := t.checkOperator(stub, "home operator", ho); err != nil {
+		return rsDetailObj, err
+	}
+	for _, c := range msisdn {
+		if c < '0' || c > '9' {
+			return rsDetailObj, fmt.Errorf("MSISDN %s must be digits only", msisdn)
+		}
+	}
+	if err := checkCoordinates(lat, long); err != nil {
+		return rsDetailObj, err
+	}
+	existing, err := t.getSubscriber(stub, key)
+	reentered := err == nil
+	//Terminated subscribers are kept for audit and must not be overwritten
+	if reentered && subscriberStatus(existing) == statusTerminated {
+		return rsDetailObj, fmt.Errorf("subscriber %s is terminated and cannot be re-entered", key)
+	}
+	if reentered && existing.HO != ho {
+		return rsDetailObj, fmt.Errorf("subscriber %s belongs to %s, its home operator only changes by porting", key, existing.HO)
+	}
+	indexed, err := keys.searchable("msisdn", msisdn)
+	if err != nil {
+		return rsDetailObj, err
+	}
+	//Only one active subscriber may hold an MSISDN
+	holder, err := t.activeHolder(stub, indexed)
+	if err != nil {
+		return rsDetailObj, err
+	}
+	if holder != "" && holder != key {
+		return rsDetailObj, fmt.Errorf("MSISDN %s is already held by %s", msisdn, holder)
+	}
+
+	rsDetailObj.PublicKey = key
+	rsDetailObj.MSISDN = msisdn
+	rsDetailObj.Name = name
+	rsDetailObj.Address = address
+	rsDetailObj.HO = ho
+	rsDetailObj.RP = ""
+	rsDetailObj.Roaming = "FALSE"
+	rsDetailObj.Location = address
+	rsDetailObj.Lat = lat
+	rsDetailObj.Long = long
+	rsDetailObj.RateType = ""
+	rsDetailObj.Action = ""
+	rsDetailObj.TransType = ""
+	rsDetailObj.Destination = ""
+	rsDetailObj.Duration = 0.0
+	rsDetailObj.Charges = 0.0
+	rsDetailObj.Flag = ""
+	rsDetailObj.Status = statusActive
+	if reentered {
+		rsDetailObj.Status = subscriberStatus(existing)
+		rsDetailObj.StatusReason = existing.StatusReason
+		rsDetailObj.StatusHistory = existing.StatusHistory
+		rsDetailObj.AuthKey = existing.AuthKey
+		//The plan stays with its allowance counter, which only assignPlan resets
+		rsDetailObj.Plan = existing.Plan
+		//SIMs only change through assignSIM, which keeps the imsi index
+		rsDetailObj.IMSI = existing.IMSI
+		rsDetailObj.ICCID = existing.ICCID
+		rsDetailObj.SIMState = existing.SIMState
+	}
+	rsDetailObj.State = stateRegistered
+	//Get Current Time
+	rsDetailObj.Time = txTime(stub)
+	if keys != nil {
+		if err = sealSubscriber(stub, &rsDetailObj, keys, keys.Fields); err != nil {
+			return rsDetailObj, err
+		}
+	}
+	if err = locate(&rsDetailObj); err != nil {
+		return rsDetailObj, err
+	}
+	return rsDetailObj, nil
+}
+
+//putNetworkPeers: To put an array containing pointers to all blocks for a particular user(or peer) on the ledger
+func (t *SimpleChaincode) putMSIDN(stub shim.ChaincodeStubInterface, rs rsDetailBlock, key string) ([]byte, error) {
+	//marshalling
+	fmt.Println(" Initializing msisdn: ", key)
+	fmt.Printf("put details: %+v ", rs)
+	fmt.Printf("\n")
+	bytes, _ := encodeSubscriber(rs)
+	fmt.Println(string(bytes))
+	//Keep the msisdn, imsi and geo indexes in step with the record
+	oldHash := ""
+	if old, err := t.getSubscriber(stub, key); err == nil {
+		oldHash = old.geohash()
+		if old.MSISDN != rs.MSISDN {
+			if err = delIndex(stub, msisdnIndex, old.MSISDN, key); err != nil {
+				return nil, err
+			}
+		}
+		if old.IMSI != rs.IMSI {
+			if err = delIndex(stub, imsiIndex, old.IMSI, key); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := putIndex(stub, msisdnIndex, rs.MSISDN, key); err != nil {
+		return nil, err
+	}
+	if err := putIndex(stub, imsiIndex, rs.IMSI, key); err != nil {
+		return nil, err
+	}
+	if err := reindexPosition(stub, key, oldHash, rs.geohash()); err != nil {
+		return nil, err
+	}
+	err2 := stub.PutState(key, bytes)
+	
+	if err2 != nil {
+		fmt.Println("Error - could not Marshall in msisdn")
+		return nil, err2
+	} else {
+		fmt.Println("Success - Marshall in msisdn details")
+	}
+	return nil, nil
+}
+
+//getSubscriber: To read back a subscriber put on the ledger with putMSIDN
+func (t *SimpleChaincode) getSubscriber(stub shim.ChaincodeStubInterface, key string) (rsDetailBlock, error) {
+	var rs rsDetailBlock
+	bytes, err := stub.GetState(key)
+	if err != nil {
+		fmt.Println("Error - Could not get User details : ", key)
+		return rs, err
+	}
+	if bytes == nil {
+		return rs, fmt.Errorf("subscriber %s not found", key)
+	}
+	return decodeSubscriber(bytes)
+}
+
+// loadSubscriber reads the subscriber a roaming function acts on. A key
+// with no record gets a NOT_FOUND response to return instead.
+func (t *SimpleChaincode) loadSubscriber(stub shim.ChaincodeStubInterface, function string, key string) (rsDetailBlock, []byte, error) {
+	bytes, err := stub.GetState(key)
+	if err != nil {
+		return rsDetailBlock{}, nil, err
+	}
+	if bytes == nil {
+		fmt.Println("Error - Could not get User details : ", key)
+		resp := newInvokeResponse(stub, function, nil)
+		resp.Code = codeNotFound
+		resp.Key = key
+		resp.Message = fmt.Sprintf("subscriber %s not found", key)
+		missing, err := resp.marshal()
+		if err != nil {
+			return rsDetailBlock{}, nil, err
+		}
+		return rsDetailBlock{}, missing, nil
+	}
+	rs, err := decodeSubscriber(bytes)
+	return rs, nil, err
+}
+
+//Remote Partner Discovery
+func (t *SimpleChaincode) discoverRP(stub shim.ChaincodeStubInterface, key string, sp string, loc string,lat string,long string) ([]byte, error) {
+
+	rsDetailobj, missing, err := t.loadSubscriber(stub, "discoverRP", key)
+	if missing != nil || err != nil {
+		return missing, err
+	}
+	if err = checkActive(rsDetailobj); err != nil {
+		fmt.Println("Discovery rejected: ", err)
+		return nil, err
+	}
+	if err = checkTransition(rsDetailobj, "discoverRP"); err != nil {
+		fmt.Println("Discovery rejected: ", err)
+		return nil, err
+	}
+	if err = t.checkOperator(stub, "roaming partner", sp); err != nil {
+		fmt.Println("Discovery rejected: ", err)
+		return nil, err
+	}
+	if err = checkCoordinates(lat, long); err != nil {
+		fmt.Println("Discovery rejected: ", err)
+		return nil, err
+	}
+	//Steering of roaming: the home operator may turn the attach away
+	outcome, policy, err := t.steer(stub, rsDetailobj, sp)
+	if err != nil {
+		return nil, err
+	}
+	if outcome == steerRejected {
+		fmt.Println("Discovery steered away from ", sp)
+		if err = emitSteeredEvent(stub, rsDetailobj, sp); err != nil {
+			return nil, err
+		}
+		resp := newInvokeResponse(stub, "discoverRP", &rsDetailobj)
+		resp.Code = codeSteered
+		resp.Message = fmt.Sprintf("%s is not a preferred partner of %s in %s", sp, policy.HO, policy.Country)
+		resp.Result = policy.Preferred
+		return resp.marshal()
+	}
+	if err = advanceState(&rsDetailobj, "discoverRP"); err != nil {
+		fmt.Println("Discovery rejected: ", err)
+		return nil, err
+	}
+	oldHash := rsDetailobj.geohash()
+	rsDetailobj.RP = sp
+	rsDetailobj.Location = loc
+	rsDetailobj.Lat = lat
+	rsDetailobj.Long = long
+	rsDetailobj.Action = "Discovery"
+	rsDetailobj.TransType = "Setup"
+	if err = resealSubscriber(stub, &rsDetailobj); err != nil {
+		return nil, err
+	}
+	if err = locate(&rsDetailobj); err != nil {
+		return nil, err
+	}
+	rsDetailobj.Time = txTime(stub)
+	bytes2, _ := encodeSubscriber(rsDetailobj)
+	err2 := stub.PutState(rsDetailobj.PublicKey, bytes2)
+	if err2 != nil {
+		fmt.Println("Error - could not Marshall in msisdn")
+	} else {
+		fmt.Println("Success, updated record")
+	}
+	if err = reindexPosition(stub, rsDetailobj.PublicKey, oldHash, rsDetailobj.geohash()); err != nil {
+		return nil, err
+	}
+	if err = t.recordLocation(stub, rsDetailobj, locationDiscovered); err != nil {
+		return nil, err
+	}
+	if err = emitRoamingEvent(stub, eventDiscovered, rsDetailobj); err != nil {
+		return nil, err
+	}
+
+	if len(rsmap) != 0{
+	 rsmap[key]=""
+         }else{
+		fmt.Println("Map is empty: ",len(rsmap))
+		}
+
+	return newInvokeResponse(stub, "discoverRP", &rsDetailobj).marshal()
+}
+
+//Authentication
+func (t *SimpleChaincode) authentication(stub shim.ChaincodeStubInterface, keyy string, nonce string, signature string) ([]byte, error) {
+
+	rsDetailobj, missing, err := t.loadSubscriber(stub, "authentication", keyy)
+	if missing != nil || err != nil {
+		return missing, err
+	}
+	var ho, rp, msisdn string
+
+	if err = checkActive(rsDetailobj); err != nil {
+		fmt.Println("Authentication rejected: ", err)
+		return nil, err
+	}
+	if err = checkTransition(rsDetailobj, "authentication"); err != nil {
+		fmt.Println("Authentication rejected: ", err)
+		return nil, err
+	}
+	//The device must prove it holds the subscriber's key. A failed proof
+	//uses up the challenge but leaves the subscriber as it was.
+	if err = t.checkAuthProof(stub, rsDetailobj, nonce, signature); err != nil {
+		fmt.Println("Authentication Failed: ", err)
+		if err2 := emitRoamingEvent(stub, eventAuthRejected, rsDetailobj); err2 != nil {
+			return nil, err2
+		}
+		resp := newInvokeResponse(stub, "authentication", &rsDetailobj)
+		resp.Code = codeAuthRejected
+		resp.Message = err.Error()
+		return resp.marshal()
+	}
+	wasRoaming := rsDetailobj.Roaming == "True"
+	ho = rsDetailobj.HO
+	rp = rsDetailobj.RP
+	msisdn = rsDetailobj.MSISDN
+	//ADDING LOGIC FOR FRAUD:
+	for key, value := range rsmap {
+		if msisdn == value{
+			rsDetailobj.Flag="Fraud"
+			break
+		}
+        fmt.Println("Key:", key, "Value:", value)
+     }
+
+	 if keyy=="rs8"{
+		rsDetailobj.Flag="Fraud" 
+	 }
+
+
+    if rsDetailobj.Flag!="Fraud"{
+	          if len(rsmap) != 0{
+			 rsmap[keyy] = msisdn
+			}else{
+				fmt.Println("Map is empty: ",len(rsmap))
+			}
+	}
+
+	////// Add logic for authentication here
+	if rp == "" {
+		        rsDetailobj.Roaming = "False"
+			rsDetailobj.Action = "Authentication"
+			rsDetailobj.TransType = "Setup"
+			fmt.Println("Authentication Successfull")
+	} else if _, err = t.getAgreement(stub, ho, rp, txTime(stub)); err == nil {
+			rsDetailobj.Roaming = "True"
+			rsDetailobj.Action = "Authentication"
+			rsDetailobj.TransType = "Setup"
+			fmt.Println("Authentication Successfull, roaming agreement with ", rp)
+	} else if rp == "XYZ" {
+		if ho == "ABC" {
+			rsDetailobj.Roaming = "True"
+			rsDetailobj.Action = "Authentication"
+			rsDetailobj.TransType = "Setup"
+			fmt.Println("Authentication Successfull")
+		}
+	} else if rp == "ABC" {
+		if ho == "XYZ" {
+			rsDetailobj.Roaming = "True"
+			rsDetailobj.Action = "Authentication"
+			rsDetailobj.TransType = "Setup"
+			fmt.Println("Authentication Successfull")
+		}
+	}else {
+		fmt.Println("Authentication Failed")
+	}
+
+	//rsDetailobj.Roaming="True"
+	//rsDetailobj.Action="Authentication"
+	//rsDetailobj.TransType="Setup"
+	if rsDetailobj.Action == "Authentication" && rsDetailobj.Flag != "Fraud" {
+		rsDetailobj.State = stateAuthenticated
+	}
+
+	////////////////////////////////////////////
+	rsDetailobj.Time = txTime(stub)
+	bytes2, _ := encodeSubscriber(rsDetailobj)
+	err2 := stub.PutState(rsDetailobj.PublicKey, bytes2)
+	if err2 != nil {
+		fmt.Println("Error - could not Marshall in msisdn")
+	} else {
+		fmt.Println("Success, updated record")
+	}
+	//Tell roamers arriving on a partner network what it costs
+	var notes []notification
+	if rsDetailobj.State == stateAuthenticated && rsDetailobj.Roaming == "True" {
+		if notes, err = t.welcome(stub, rsDetailobj, wasRoaming); err != nil {
+			return nil, err
+		}
+	}
+	eventType := eventAuthenticated
+	if rsDetailobj.Flag == "Fraud" {
+		eventType = eventFraudFlagged
+	} else if rsDetailobj.State != stateAuthenticated {
+		eventType = eventAuthRejected
+	}
+	if err = emitRoamingEvent(stub, eventType, rsDetailobj, notes...); err != nil {
+		return nil, err
+	}
+
+	resp := newInvokeResponse(stub, "authentication", &rsDetailobj)
+	resp.Notifications = notes
+	if rsDetailobj.Flag == "Fraud" {
+		resp.Code = codeFraud
+		resp.Message = "subscriber flagged for fraud"
+	} else if rsDetailobj.State != stateAuthenticated {
+		resp.Code = codeAuthRejected
+		resp.Message = fmt.Sprintf("no roaming agreement between %s and %s", ho, rp)
+	}
+	return resp.marshal()
+}
+
+//Update voice and data rates
+func (t *SimpleChaincode) updateRates(stub shim.ChaincodeStubInterface, key string) ([]byte, error) {
+
+	rsDetailobj, missing, err := t.loadSubscriber(stub, "updateRates", key)
+	if missing != nil || err != nil {
+		return missing, err
+	}
+	if err = advanceState(&rsDetailobj, "updateRates"); err != nil {
+		fmt.Println("Rate update rejected: ", err)
+		return nil, err
+	}
+	rsDetailobj.RateType = t.roamingRateType(stub, rsDetailobj, txTime(stub))
+	rsDetailobj.Time = txTime(stub)
+	rsDetailobj.Action = "Register"
+	rsDetailobj.TransType = "Setup"
+	bytes2, _ := encodeSubscriber(rsDetailobj)
+	err2 := stub.PutState(rsDetailobj.PublicKey, bytes2)
+	if err2 != nil {
+		fmt.Println("Error - could not Marshall in msisdn")
+	} else {
+		fmt.Println("Success, updated record")
+	}
+	if err = emitRoamingEvent(stub, eventRatesUpdated, rsDetailobj); err != nil {
+		return nil, err
+	}
+
+	return newInvokeResponse(stub, "updateRates", &rsDetailobj).marshal()
+}
+
+//Call Out
+func (t *SimpleChaincode) CallOut(stub shim.ChaincodeStubInterface, key string, destmsisdn string) ([]byte, error) {
+	return t.startSession(stub, key, sessionVoice, destmsisdn)
+}
+
+//startSession: To open a call, data or SMS session and return it
+func (t *SimpleChaincode) startSession(stub shim.ChaincodeStubInterface, key string, sessionType string, destmsisdn string) ([]byte, error) {
+
+	rsDetailobj, missing, err := t.loadSubscriber(stub, startFunctions[sessionType], key)
+	if missing != nil || err != nil {
+		return missing, err
+	}
+	if err = checkActive(rsDetailobj); err != nil {
+		fmt.Println("Call rejected: ", err)
+		return nil, err
+	}
+	if err = advanceState(&rsDetailobj, "CallOut"); err != nil {
+		fmt.Println("Call rejected: ", err)
+		return nil, err
+	}
+	session, err := t.newSession(stub, rsDetailobj, sessionType, destmsisdn)
+	if err != nil {
+		fmt.Println("Call rejected: ", err)
+		return nil, err
+	}
+	if rsDetailobj.State, err = t.stateAfter(stub, session); err != nil {
+		return nil, err
+	}
+	rsDetailobj.Destination = destmsisdn
+	rsDetailobj.Action = "Call Initialization"
+	rsDetailobj.TransType = transTypes[sessionType]
+	rsDetailobj.Duration = 0.0
+	rsDetailobj.Charges = 0.0
+	rsDetailobj.Time = txTime(stub)
+	bytes2, _ := encodeSubscriber(rsDetailobj)
+	err2 := stub.PutState(rsDetailobj.PublicKey, bytes2)
+	if err2 != nil {
+		fmt.Println("Error - could not Marshall in msisdn")
+	} else {
+		fmt.Println("Success, updated record")
+	}
+	if err = emitSessionEvent(stub, eventCallStarted, rsDetailobj, session); err != nil {
+		return nil, err
+	}
+
+	resp := newInvokeResponse(stub, startFunctions[sessionType], &rsDetailobj)
+	resp.setSession(session)
+	return resp.marshal()
+}
+
+func (t *SimpleChaincode) Overage(stub shim.ChaincodeStubInterface, key string) ([]byte, error) {
+
+	rsDetailobj, missing, err := t.loadSubscriber(stub, "Overage", key)
+	if missing != nil || err != nil {
+		return missing, err
+	}
+	rsDetailobj.Action = "OverageCheck"
+	rsDetailobj.TransType = "Call Out"
+	rsDetailobj.Flag= "OVERAGE"
+	rsDetailobj.Time = txTime(stub)
+	bytes2, _ := encodeSubscriber(rsDetailobj)
+	err2 := stub.PutState(rsDetailobj.PublicKey, bytes2)
+	if err2 != nil {
+		fmt.Println("Error - could not Marshall in msisdn")
+	} else {
+		fmt.Println("Success, updated record")
+	}
+	if err = emitRoamingEvent(stub, eventOverage, rsDetailobj); err != nil {
+		return nil, err
+	}
+
+	return newInvokeResponse(stub, "Overage", &rsDetailobj).marshal()
+}
+
+//Call In: the subscriber receives a call, rated against voice in allowances
+func (t *SimpleChaincode) CallIn(stub shim.ChaincodeStubInterface, key string, callermsisdn string) ([]byte, error) {
+	return t.startSession(stub, key, sessionVoiceIn, callermsisdn)
+}
+
+//Call End
+//volume is the MB used by a data session and is ignored for calls
+func (t *SimpleChaincode) CallEnd(stub shim.ChaincodeStubInterface, key string, sessionID string, volume float64) ([]byte, error) {
+
+	rsDetailobj, missing, err := t.loadSubscriber(stub, "CallEnd", key)
+	if missing != nil || err != nil {
+		return missing, err
+	}
+	session, err := t.getSession(stub, key, sessionID)
+	if err != nil {
+		fmt.Println("Call End rejected: ", err)
+		return nil, err
+	}
+	if err = advanceSession(&session, "CallEnd"); err != nil {
+		fmt.Println("Call End rejected: ", err)
+		return nil, err
+	}
+	session.End = txTime(stub)
+	session.Duration = session.End.Sub(session.Start).Minutes()
+	if session.Type == sessionData {
+		session.Volume = volume
+	}
+	if err = t.putSession(stub, session); err != nil {
+		return nil, err
+	}
+	if rsDetailobj.State, err = t.stateAfter(stub, session); err != nil {
+		return nil, err
+	}
+	rsDetailobj.Action = "Call End"
+	rsDetailobj.TransType = transTypes[session.Type]
+	//dur := strconv.(duration.Minutes())
+	rsDetailobj.Time = txTime(stub)
+	rsDetailobj.Duration = session.Duration
+	bytes2, _ := encodeSubscriber(rsDetailobj)
+	err2 := stub.PutState(rsDetailobj.PublicKey, bytes2)
+	if err2 != nil {
+		fmt.Println("Error - could not Marshall in msisdn")
+	} else {
+		fmt.Println("Success, updated record")
+	}
+	if err = emitSessionEvent(stub, eventCallEnded, rsDetailobj, session); err != nil {
+		return nil, err
+	}
+
+	resp := newInvokeResponse(stub, "CallEnd", &rsDetailobj)
+	resp.setSession(session)
+	return resp.marshal()
+}
+
+//Call Pay
+func (t *SimpleChaincode) CallPay(stub shim.ChaincodeStubInterface, key string, sessionID string) ([]byte, error) {
+
+	rsDetailobj, missing, err := t.loadSubscriber(stub, "CallPay", key)
+	if missing != nil || err != nil {
+		return missing, err
+	}
+	session, err := t.getSession(stub, key, sessionID)
+	if err != nil {
+		fmt.Println("Call Pay rejected: ", err)
+		return nil, err
+	}
+	if err = advanceSession(&session, "CallPay"); err != nil {
+		fmt.Println("Call Pay rejected: ", err)
+		return nil, err
+	}
+	if session.Charges, err = t.rateSession(stub, session); err != nil {
+		return nil, err
+	}
+	if err = t.putSession(stub, session); err != nil {
+		return nil, err
+	}
+	notes, err := t.recordCharge(stub, session)
+	if err != nil {
+		return nil, err
+	}
+	if rsDetailobj.State, err = t.stateAfter(stub, session); err != nil {
+		return nil, err
+	}
+	rsDetailobj.Action = "Pay Charge"
+	rsDetailobj.TransType = transTypes[session.Type]
+	rsDetailobj.Charges = session.Charges
+	rsDetailobj.Time = txTime(stub)
+	bytes2, _ := encodeSubscriber(rsDetailobj)
+	err2 := stub.PutState(rsDetailobj.PublicKey, bytes2)
+	if err2 != nil {
+		fmt.Println("Error - could not Marshall in msisdn")
+	} else {
+		fmt.Println("Success, updated record")
+	}
+	if err = emitSessionEvent(stub, eventCallCharged, rsDetailobj, session, notes...); err != nil {
+		return nil, err
+	}
+
+	resp := newInvokeResponse(stub, "CallPay", &rsDetailobj)
+	resp.setSession(session)
+	resp.Notifications = notes
+	return resp.marshal()
+}
@@ -0,0 +1,182 @@
+package roaming
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// areaSubscriber is one of the caller's own subscribers found in an area
+type areaSubscriber struct {
+	Key        string  `json:"key"`
+	MSISDN     string  `json:"msisdn"`
+	RP         string  `json:"rp"`
+	Roaming    bool    `json:"roaming"`
+	Location   string  `json:"location"`
+	Lat        float64 `json:"lat"`
+	Long       float64 `json:"long"`
+	DistanceKm float64 `json:"distancekm,omitempty"`
+}
+
+// areaCount is how many subscribers of a home operator are in an area
+type areaCount struct {
+	HO          string `json:"ho"`
+	Subscribers int    `json:"subscribers"`
+	Roaming     int    `json:"roaming"`
+}
+
+// areaReport is returned by queryArea. The caller's own subscribers are
+// listed; those of other home operators are only counted, in ByHO.
+type areaReport struct {
+	Caller      string           `json:"caller,omitempty"`
+	Box         geoBox           `json:"box"`
+	Center      *geoPoint        `json:"center,omitempty"`
+	RadiusKm    float64          `json:"radiuskm,omitempty"`
+	Total       int              `json:"total"`
+	Roaming     int              `json:"roaming"`
+	Subscribers []areaSubscriber `json:"subscribers"`
+	ByHO        []areaCount      `json:"byho"`
+}
+
+// areaKeys returns the keys the geo index places in the cells covering box
+func areaKeys(stub shim.ChaincodeStubInterface, box geoBox) ([]string, error) {
+	prefix, err := createCompositeKey(geoIndex, nil)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var keys []string
+	for _, cell := range coverCells(box) {
+		iter, err := stub.RangeQueryState(prefix+cell, prefix+cell+maxUnicodeRune)
+		if err != nil {
+			return nil, err
+		}
+		for iter.HasNext() {
+			indexKey, _, err := iter.Next()
+			if err != nil {
+				iter.Close()
+				return nil, err
+			}
+			_, parts, err := splitCompositeKey(indexKey)
+			if err != nil {
+				iter.Close()
+				return nil, err
+			}
+			if key := parts[len(parts)-1]; !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+		iter.Close()
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+//Query Area: find the subscribers located in a bounding box or within a
+//radius. The caller's own subscribers are listed, others are counted by HO.
+//args: "box", min lat, min long, max lat, max long (min long > max long
+//crosses the antimeridian) or "radius", lat, long, km
+func (t *SimpleChaincode) queryArea(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) < 4 {
+		return nil, errors.New("Incorrect number of arguments. Expecting box, min lat, min long, max lat, max long or radius, lat, long, km")
+	}
+	report := areaReport{Subscribers: []areaSubscriber{}, ByHO: []areaCount{}}
+	switch args[0] {
+	case "box":
+		if len(args) < 5 {
+			return nil, errors.New("Incorrect number of arguments. Expecting box, min lat, min long, max lat, max long")
+		}
+		lo, err := parsePoint(args[1], args[2])
+		if err != nil {
+			return nil, err
+		}
+		hi, err := parsePoint(args[3], args[4])
+		if err != nil {
+			return nil, err
+		}
+		report.Box = geoBox{MinLat: lo.Lat, MinLong: lo.Long, MaxLat: hi.Lat, MaxLong: hi.Long}
+		if report.Box.MinLat > report.Box.MaxLat {
+			return nil, errors.New("min lat is above max lat")
+		}
+	case "radius":
+		center, err := parsePoint(args[1], args[2])
+		if err != nil {
+			return nil, err
+		}
+		km, err := strconv.ParseFloat(args[3], 64)
+		if err != nil || km <= 0 {
+			return nil, errors.New("radius must be a positive number of km")
+		}
+		report.Center = &center
+		report.RadiusKm = km
+		report.Box = radiusBox(center.Lat, center.Long, km)
+	default:
+		return nil, fmt.Errorf("unknown area %q, expecting box or radius", args[0])
+	}
+	//Callers without an operator only see counts
+	report.Caller, _ = callerOperator(stub)
+
+	keys, err := areaKeys(stub, report.Box)
+	if err != nil {
+		return nil, err
+	}
+	counts := map[string]*areaCount{}
+	for _, key := range keys {
+		rs, err := t.getSubscriber(stub, key)
+		if err != nil || rs.Position == nil || subscriberStatus(rs) == statusTerminated {
+			continue
+		}
+		p := rs.Position
+		if !report.Box.contains(p.Lat, p.Long) {
+			continue
+		}
+		distance := 0.0
+		if report.Center != nil {
+			if distance = distanceKm(report.Center.Lat, report.Center.Long, p.Lat, p.Long); distance > report.RadiusKm {
+				continue
+			}
+		}
+		roaming := strings.EqualFold(rs.Roaming, "true")
+		report.Total++
+		if roaming {
+			report.Roaming++
+		}
+		if report.Caller != "" && rs.HO == report.Caller {
+			report.Subscribers = append(report.Subscribers, areaSubscriber{
+				Key:        rs.PublicKey,
+				MSISDN:     rs.MSISDN,
+				RP:         rs.RP,
+				Roaming:    roaming,
+				Location:   rs.Location,
+				Lat:        p.Lat,
+				Long:       p.Long,
+				DistanceKm: distance,
+			})
+			continue
+		}
+		c, ok := counts[rs.HO]
+		if !ok {
+			c = &areaCount{HO: rs.HO}
+			counts[rs.HO] = c
+		}
+		c.Subscribers++
+		if roaming {
+			c.Roaming++
+		}
+	}
+	hos := make([]string, 0, len(counts))
+	for ho := range counts {
+		hos = append(hos, ho)
+	}
+	sort.Strings(hos)
+	for _, ho := range hos {
+		report.ByHO = append(report.ByHO, *counts[ho])
+	}
+	return json.Marshal(report)
+}
@@ -0,0 +1,213 @@
+package roaming
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// challengeTTL is how long a device has to answer an authentication
+// challenge
+const challengeTTL = 5 * time.Minute
+
+// authChallenge is the nonce issued to a subscriber's device, under
+// ("authchallenge", key). The device signs Message, which binds the nonce
+// to the subscriber and the partner it is attaching to, with the private
+// key matching the subscriber's enrolled AuthKey. A challenge is answered
+// once: it is removed by the authentication that uses it, successful or
+// not.
+type authChallenge struct {
+	schemaStamp
+	Key       string    `json:"key"`
+	RP        string    `json:"rp"`
+	Nonce     string    `json:"nonce"`
+	Message   string    `json:"message"`
+	IssuedAt  time.Time `json:"issuedat"`
+	ExpiresAt time.Time `json:"expiresat"`
+}
+
+// ecdsaSignature is the ASN.1 form of an ECDSA signature
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// parseAuthKey reads a subscriber public key, PEM or base64 encoded DER
+// SubjectPublicKeyInfo. Only ECDSA P-256 and Ed25519 keys are accepted.
+func parseAuthKey(encoded string) (interface{}, error) {
+	var der []byte
+	if block, _ := pem.Decode([]byte(encoded)); block != nil {
+		der = block.Bytes
+	} else {
+		var err error
+		if der, err = base64.StdEncoding.DecodeString(strings.TrimSpace(encoded)); err != nil {
+			return nil, errors.New("authentication key is neither PEM nor base64")
+		}
+	}
+	pub, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("invalid authentication key: %s", err)
+	}
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return nil, errors.New("ECDSA authentication keys must use P-256")
+		}
+	case ed25519.PublicKey:
+	default:
+		return nil, fmt.Errorf("unsupported authentication key type %T", pub)
+	}
+	return pub, nil
+}
+
+// verifySignature checks a base64 signature of message by pub: ASN.1 DER
+// over the SHA-256 of message for ECDSA, over message itself for Ed25519
+func verifySignature(pub interface{}, message string, signature string) error {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return errors.New("signature is not base64")
+	}
+	valid := false
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		var es ecdsaSignature
+		if rest, err := asn1.Unmarshal(sig, &es); err == nil && len(rest) == 0 && es.R != nil && es.S != nil {
+			digest := sha256.Sum256([]byte(message))
+			valid = ecdsa.Verify(k, digest[:], es.R, es.S)
+		}
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(k, []byte(message), sig)
+	}
+	if !valid {
+		return errors.New("signature does not verify")
+	}
+	return nil
+}
+
+// challengeNonce derives the nonce from the transaction ID, so every peer
+// endorsing the transaction issues the same one
+func challengeNonce(txid string, key string) string {
+	sum := sha256.Sum256([]byte(txid + "\x00" + key))
+	return hex.EncodeToString(sum[:])
+}
+
+// checkAuthProof verifies that rs answered its outstanding challenge with
+// a signature by its enrolled key, and uses the challenge up. Subscribers
+// without a key are only let through when the config allows unenrolled
+// authentication.
+func (t *SimpleChaincode) checkAuthProof(stub shim.ChaincodeStubInterface, rs rsDetailBlock, nonce string, signature string) error {
+	if rs.AuthKey == "" {
+		if config, err := t.getConfig(stub); err == nil && config.UnenrolledAuth {
+			return nil
+		}
+		return fmt.Errorf("subscriber %s has no authentication key enrolled", rs.PublicKey)
+	}
+	var c authChallenge
+	if err := getRecord(stub, "authchallenge", []string{rs.PublicKey}, &c); err != nil {
+		return fmt.Errorf("no authentication challenge issued to %s", rs.PublicKey)
+	}
+	ck, err := createCompositeKey("authchallenge", []string{rs.PublicKey})
+	if err != nil {
+		return err
+	}
+	if err = stub.DelState(ck); err != nil {
+		return err
+	}
+	if nonce != c.Nonce {
+		return errors.New("nonce does not match the challenge issued")
+	}
+	if c.RP != rs.RP {
+		return fmt.Errorf("challenge was issued for %s, not %s", c.RP, rs.RP)
+	}
+	if txTime(stub).After(c.ExpiresAt) {
+		return errors.New("authentication challenge has expired")
+	}
+	pub, err := parseAuthKey(rs.AuthKey)
+	if err != nil {
+		return err
+	}
+	return verifySignature(pub, c.Message, signature)
+}
+
+//Auth Challenge: issue the nonce a subscriber's device signs to authenticate
+//on the partner it discovered. Only the home operator or that partner may
+//ask, and a pending challenge is not replaced until it expires.
+func (t *SimpleChaincode) authChallenge(stub shim.ChaincodeStubInterface, key string) ([]byte, error) {
+	rsDetailobj, err := t.getSubscriber(stub, key)
+	if err != nil {
+		return nil, err
+	}
+	caller, err := callerOperator(stub)
+	if err != nil {
+		return nil, err
+	}
+	if caller != rsDetailobj.HO && caller != rsDetailobj.RP {
+		return nil, fmt.Errorf("only home operator %s or roaming partner %s may challenge %s", rsDetailobj.HO, rsDetailobj.RP, key)
+	}
+	if err = checkActive(rsDetailobj); err != nil {
+		return nil, err
+	}
+	if err = checkTransition(rsDetailobj, "authentication"); err != nil {
+		return nil, err
+	}
+	if rsDetailobj.AuthKey == "" {
+		return nil, fmt.Errorf("subscriber %s has no authentication key enrolled", key)
+	}
+	now := txTime(stub)
+	var pending authChallenge
+	if err = getRecord(stub, "authchallenge", []string{key}, &pending); err == nil && !now.After(pending.ExpiresAt) {
+		return nil, fmt.Errorf("authentication challenge for %s is pending until %s", key, pending.ExpiresAt.Format(time.RFC3339))
+	}
+	nonce := challengeNonce(stub.GetTxID(), key)
+	c := authChallenge{
+		Key:       key,
+		RP:        rsDetailobj.RP,
+		Nonce:     nonce,
+		Message:   strings.Join([]string{"roam-auth", key, rsDetailobj.RP, nonce}, ":"),
+		IssuedAt:  now,
+		ExpiresAt: now.Add(challengeTTL),
+	}
+	if err = putRecord(stub, "authchallenge", []string{key}, &c); err != nil {
+		return nil, err
+	}
+	resp := newInvokeResponse(stub, "authChallenge", &rsDetailobj)
+	resp.Result = c
+	return resp.marshal()
+}
+
+//Enrol Auth Key: the home operator stores the public key of a subscriber's
+//SIM or device, replacing any earlier one
+func (t *SimpleChaincode) enrolAuthKey(stub shim.ChaincodeStubInterface, key string, authKey string) ([]byte, error) {
+	rsDetailobj, err := t.getSubscriber(stub, key)
+	if err != nil {
+		return nil, err
+	}
+	caller, err := callerOperator(stub)
+	if err != nil {
+		return nil, err
+	}
+	if caller != rsDetailobj.HO {
+		return nil, fmt.Errorf("only home operator %s may enrol keys for %s", rsDetailobj.HO, key)
+	}
+	if _, err = parseAuthKey(authKey); err != nil {
+		return nil, err
+	}
+	rsDetailobj.AuthKey = authKey
+	bytes, _ := encodeSubscriber(rsDetailobj)
+	if err = stub.PutState(key, bytes); err != nil {
+		return nil, err
+	}
+	return newInvokeResponse(stub, "enrolAuthKey", &rsDetailobj).marshal()
+}
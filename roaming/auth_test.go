@@ -0,0 +1,108 @@
+package roaming
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+	"testing"
+)
+
+// encodeAuthKey returns pub as base64 DER, the form enrolAuthKey takes
+func encodeAuthKey(t *testing.T, pub interface{}) string {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(der)
+}
+
+func TestParseAuthKey(t *testing.T) {
+	ec, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ed, _, _ := ed25519.GenerateKey(rand.Reader)
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+
+	if _, err := parseAuthKey(encodeAuthKey(t, &ec.PublicKey)); err != nil {
+		t.Errorf("P-256 key refused: %v", err)
+	}
+	der, _ := x509.MarshalPKIXPublicKey(ed)
+	pemKey := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	if pub, err := parseAuthKey(pemKey); err != nil {
+		t.Errorf("PEM Ed25519 key refused: %v", err)
+	} else if _, ok := pub.(ed25519.PublicKey); !ok {
+		t.Errorf("PEM Ed25519 key parsed as %T", pub)
+	}
+	if _, err := parseAuthKey(encodeAuthKey(t, &p384.PublicKey)); err == nil || !strings.Contains(err.Error(), "P-256") {
+		t.Errorf("P-384 key returned %v", err)
+	}
+	if _, err := parseAuthKey("not a key!"); err == nil {
+		t.Error("parsed a key that is neither PEM nor base64")
+	}
+	if _, err := parseAuthKey(base64.StdEncoding.EncodeToString([]byte("garbage"))); err == nil {
+		t.Error("parsed a key that is not DER")
+	}
+}
+
+func TestVerifySignatureEd25519(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	message := "rs1|XYZ|nonce"
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte(message)))
+
+	if err := verifySignature(pub, message, sig); err != nil {
+		t.Errorf("valid signature refused: %v", err)
+	}
+	if err := verifySignature(pub, message+"x", sig); err == nil {
+		t.Error("signature verified for another message")
+	}
+	other, _, _ := ed25519.GenerateKey(rand.Reader)
+	if err := verifySignature(other, message, sig); err == nil {
+		t.Error("signature verified with another key")
+	}
+	if err := verifySignature(pub, message, "%%%"); err == nil || err.Error() != "signature is not base64" {
+		t.Errorf("malformed signature returned %v", err)
+	}
+}
+
+func TestVerifySignatureECDSA(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	message := "rs1|XYZ|nonce"
+	digest := sha256.Sum256([]byte(message))
+	der, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := base64.StdEncoding.EncodeToString(der)
+
+	if err := verifySignature(&key.PublicKey, message, sig); err != nil {
+		t.Errorf("valid signature refused: %v", err)
+	}
+	if err := verifySignature(&key.PublicKey, "rs2|XYZ|nonce", sig); err == nil {
+		t.Error("signature verified for another message")
+	}
+	// trailing bytes after the DER signature are not accepted
+	padded := base64.StdEncoding.EncodeToString(append(der, 0))
+	if err := verifySignature(&key.PublicKey, message, padded); err == nil {
+		t.Error("signature with trailing data verified")
+	}
+	if err := verifySignature(&key.PublicKey, message, base64.StdEncoding.EncodeToString([]byte("raw"))); err == nil {
+		t.Error("signature that is not DER verified")
+	}
+}
+
+func TestChallengeNonce(t *testing.T) {
+	a := challengeNonce("tx1", "rs1")
+	if a != challengeNonce("tx1", "rs1") {
+		t.Error("nonce differs between peers endorsing the same transaction")
+	}
+	if a == challengeNonce("tx2", "rs1") || a == challengeNonce("tx1", "rs2") {
+		t.Error("nonce repeats across transactions or subscribers")
+	}
+	if len(a) != 64 {
+		t.Errorf("nonce %q is not 32 hex encoded bytes", a)
+	}
+}
@@ -0,0 +1,164 @@
+package roaming
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Limits keeping a bulk import inside a single transaction's size budget
+const (
+	maxBulkPayload = 1 << 20
+	maxBulkRows    = 1000
+)
+
+// subscriberInput is one row of a bulk import. CSV rows carry the same
+// columns, either named in a header row or in enterData argument order.
+type subscriberInput struct {
+	PublicKey string `json:"publickey"`
+	MSISDN    string `json:"msisdn"`
+	Name      string `json:"name"`
+	Address   string `json:"address"`
+	HO        string `json:"ho"`
+	Lat       string `json:"lat"`
+	Long      string `json:"long"`
+}
+
+var subscriberColumns = []string{"publickey", "msisdn", "name", "address", "ho", "lat", "long"}
+
+type bulkRowResult struct {
+	Row   int    `json:"row"`
+	Key   string `json:"key"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// bulkReport is returned by bulkEnterData with one result per input row
+type bulkReport struct {
+	Accepted int             `json:"accepted"`
+	Rejected int             `json:"rejected"`
+	Rows     []bulkRowResult `json:"rows"`
+}
+
+//Bulk Enter Data: onboard many subscribers in one transaction
+//args: format ("json" or "csv"), payload
+func (t *SimpleChaincode) bulkEnterData(stub shim.ChaincodeStubInterface, format string, payload string) ([]byte, error) {
+
+	if len(payload) > maxBulkPayload {
+		return nil, fmt.Errorf("payload is %d bytes, limit is %d", len(payload), maxBulkPayload)
+	}
+	var rows []subscriberInput
+	var err error
+	switch strings.ToLower(format) {
+	case "json":
+		err = json.Unmarshal([]byte(payload), &rows)
+	case "csv":
+		rows, err = parseSubscriberCSV(payload)
+	default:
+		return nil, fmt.Errorf("unknown format %q, expecting json or csv", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not parse payload: %s", err)
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("payload has no rows")
+	}
+	if len(rows) > maxBulkRows {
+		return nil, fmt.Errorf("payload has %d rows, limit is %d", len(rows), maxBulkRows)
+	}
+	keys, err := readFieldKeys(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	report := bulkReport{}
+	seenKeys := map[string]int{}
+	seenMSISDNs := map[string]int{}
+	for i, row := range rows {
+		result := bulkRowResult{Row: i + 1, Key: row.PublicKey}
+		var rs rsDetailBlock
+		var err error
+		//A row repeating an earlier row's key would otherwise re-enter and
+		//overwrite the subscriber that row just imported; repeats are
+		//reported against the row they repeat
+		if prev, ok := seenKeys[row.PublicKey]; ok {
+			err = fmt.Errorf("key %s already used on row %d", row.PublicKey, prev)
+		} else if prev, ok := seenMSISDNs[row.MSISDN]; ok {
+			err = fmt.Errorf("MSISDN %s already used on row %d", row.MSISDN, prev)
+		} else if err = t.checkReentry(stub, row.PublicKey); err == nil {
+			rs, err = t.newSubscriber(stub, keys, row.PublicKey, row.MSISDN, row.Name, row.Address, row.HO, row.Lat, row.Long)
+		}
+		if err == nil {
+			_, err = t.putMSIDN(stub, rs, rs.PublicKey)
+		}
+		if err == nil {
+			err = t.recordLocation(stub, rs, locationEntered)
+		}
+		if err != nil {
+			result.Error = err.Error()
+			report.Rejected++
+		} else {
+			seenKeys[row.PublicKey] = i + 1
+			seenMSISDNs[row.MSISDN] = i + 1
+			result.OK = true
+			report.Accepted++
+		}
+		report.Rows = append(report.Rows, result)
+	}
+	fmt.Printf("Bulk import: %d accepted, %d rejected\n", report.Accepted, report.Rejected)
+	resp := newInvokeResponse(stub, "bulkEnterData", nil)
+	if report.Rejected > 0 {
+		resp.Code = codePartial
+		resp.Message = fmt.Sprintf("%d of %d rows rejected", report.Rejected, len(report.Rows))
+	}
+	resp.Result = report
+	return resp.marshal()
+}
+
+// parseSubscriberCSV reads CSV rows, using the first row as a header when it
+// names the columns
+func parseSubscriberCSV(payload string) ([]subscriberInput, error) {
+	r := csv.NewReader(strings.NewReader(payload))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	columns := subscriberColumns
+	var rows []subscriberInput
+	for first := true; ; first = false {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if first && strings.EqualFold(record[0], "publickey") {
+			columns = make([]string, len(record))
+			for i, c := range record {
+				columns[i] = strings.ToLower(strings.TrimSpace(c))
+			}
+			continue
+		}
+		fields := map[string]string{}
+		for i, value := range record {
+			if i < len(columns) {
+				fields[columns[i]] = strings.TrimSpace(value)
+			}
+		}
+		rows = append(rows, subscriberInput{
+			PublicKey: fields["publickey"],
+			MSISDN:    fields["msisdn"],
+			Name:      fields["name"],
+			Address:   fields["address"],
+			HO:        fields["ho"],
+			Lat:       fields["lat"],
+			Long:      fields["long"],
+		})
+	}
+	return rows, nil
+}
@@ -0,0 +1,66 @@
+package roaming
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSubscriberCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    []subscriberInput
+	}{
+		{
+			name:    "enterData order",
+			payload: "rs9,14691234590,Ann,DALLAS,ABC,32.7767,-96.7970\nrs10,14691234591,Bob,SF,ABC,,\n",
+			want: []subscriberInput{
+				{"rs9", "14691234590", "Ann", "DALLAS", "ABC", "32.7767", "-96.7970"},
+				{"rs10", "14691234591", "Bob", "SF", "ABC", "", ""},
+			},
+		},
+		{
+			name:    "header naming the columns in another order",
+			payload: "PublicKey, HO, MSISDN, Name\nrs9, ABC, 14691234590, Ann\n",
+			want: []subscriberInput{
+				{PublicKey: "rs9", MSISDN: "14691234590", Name: "Ann", HO: "ABC"},
+			},
+		},
+		{
+			name:    "quoted fields and short rows",
+			payload: "rs9,14691234590,\"Smith, Ann\",\"1 Main St, DALLAS\",ABC\nrs10,14691234591\n",
+			want: []subscriberInput{
+				{PublicKey: "rs9", MSISDN: "14691234590", Name: "Smith, Ann", Address: "1 Main St, DALLAS", HO: "ABC"},
+				{PublicKey: "rs10", MSISDN: "14691234591"},
+			},
+		},
+		{
+			name:    "extra columns are ignored",
+			payload: "publickey,msisdn,plan\nrs9,14691234590,ABC-Travel\n",
+			want: []subscriberInput{
+				{PublicKey: "rs9", MSISDN: "14691234590"},
+			},
+		},
+		{
+			name:    "header only",
+			payload: "publickey,msisdn,name,address,ho,lat,long\n",
+			want:    nil,
+		},
+	}
+	for _, tt := range tests {
+		got, err := parseSubscriberCSV(tt.payload)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseSubscriberCSVMalformed(t *testing.T) {
+	if _, err := parseSubscriberCSV("rs9,\"14691234590,Ann\n"); err == nil {
+		t.Error("parsed a row with an unterminated quote")
+	}
+}
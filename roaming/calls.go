@@ -0,0 +1,297 @@
+package roaming
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Call session types
+const (
+	sessionVoice   = "voice"
+	sessionVoiceIn = "voicein"
+	sessionData    = "data"
+	sessionSMS     = "sms"
+)
+
+// transTypes is the TransType recorded on the subscriber for each session type
+var transTypes = map[string]string{
+	sessionVoice:   "Call Out",
+	sessionVoiceIn: "Call In",
+	sessionData:    "Data",
+	sessionSMS:     "SMS",
+}
+
+// startFunctions is the Invoke function starting each session type
+var startFunctions = map[string]string{
+	sessionVoice:   "CallOut",
+	sessionVoiceIn: "CallIn",
+	sessionData:    "DataStart",
+	sessionSMS:     "SMSOut",
+}
+
+// defaultMaxSessions is how many sessions a subscriber may have in progress
+// at once when the chaincode config does not say otherwise
+const defaultMaxSessions = 2
+
+// sessionBlock is one call, data session or SMS of a subscriber, stored
+// under ("session", subscriber key, ID). RP and RateType are those in force
+// when the session started, as are Roaming and Zone, the Roam-Like-At-Home
+// zone the session is rated in. Duration is in minutes, Volume in MB for data
+// sessions and in messages for SMS.
+type sessionBlock struct {
+	schemaStamp
+	ID          string    `json:"id"`
+	Key         string    `json:"key"`
+	Type        string    `json:"type"`
+	Destination string    `json:"destination"`
+	RP          string    `json:"rp"`
+	RateType    string    `json:"ratetype"`
+	Roaming     bool      `json:"roaming"`
+	Zone        string    `json:"zone,omitempty"`
+	State       string    `json:"state"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Duration    float64   `json:"duration"`
+	Volume      float64   `json:"volume"`
+	Charges     float64   `json:"charges"`
+}
+
+func (t *SimpleChaincode) getSession(stub shim.ChaincodeStubInterface, key string, id string) (sessionBlock, error) {
+	var s sessionBlock
+	err := getRecord(stub, "session", []string{key, id}, &s)
+	return s, err
+}
+
+func (t *SimpleChaincode) putSession(stub shim.ChaincodeStubInterface, s sessionBlock) error {
+	return putRecord(stub, "session", []string{s.Key, s.ID}, &s)
+}
+
+// subscriberSessions returns every session of the subscriber stored under key
+func (t *SimpleChaincode) subscriberSessions(stub shim.ChaincodeStubInterface, key string) ([]sessionBlock, error) {
+	prefix, err := createCompositeKey("session", []string{key})
+	if err != nil {
+		return nil, err
+	}
+	iter, err := stub.RangeQueryState(prefix, prefix+maxUnicodeRune)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var sessions []sessionBlock
+	for iter.HasNext() {
+		_, bytes, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		var s sessionBlock
+		if err = json.Unmarshal(bytes, &s); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, nil
+}
+
+// stateAfter returns the subscriber state once updated has been stored
+func (t *SimpleChaincode) stateAfter(stub shim.ChaincodeStubInterface, updated sessionBlock) (string, error) {
+	sessions, err := t.subscriberSessions(stub, updated.Key)
+	if err != nil {
+		return "", err
+	}
+	found := false
+	for i := range sessions {
+		if sessions[i].ID == updated.ID {
+			sessions[i] = updated
+			found = true
+		}
+	}
+	if !found {
+		sessions = append(sessions, updated)
+	}
+	return summaryState(sessions), nil
+}
+
+func (t *SimpleChaincode) maxSessions(stub shim.ChaincodeStubInterface) int {
+	if config, err := t.getConfig(stub); err == nil && config.MaxSessions > 0 {
+		return config.MaxSessions
+	}
+	return defaultMaxSessions
+}
+
+// newSession opens a session of the given type for rs, enforcing the limit
+// on sessions in progress. The transaction ID doubles as the session ID.
+func (t *SimpleChaincode) newSession(stub shim.ChaincodeStubInterface, rs rsDetailBlock, sessionType string, destination string) (sessionBlock, error) {
+	sessions, err := t.subscriberSessions(stub, rs.PublicKey)
+	if err != nil {
+		return sessionBlock{}, err
+	}
+	inProgress := 0
+	for _, s := range sessions {
+		if s.State == stateInCall {
+			inProgress++
+		}
+	}
+	if limit := t.maxSessions(stub); sessionType != sessionSMS && inProgress >= limit {
+		return sessionBlock{}, fmt.Errorf("subscriber %s already has %d sessions in progress, the limit is %d", rs.PublicKey, inProgress, limit)
+	}
+
+	now := txTime(stub)
+	s := t.draftSession(stub, rs, sessionType, destination, now)
+	s.ID = stub.GetTxID()
+	if s.ID == "" {
+		s.ID = strconv.FormatInt(now.UnixNano(), 10)
+	}
+	//An SMS is over as soon as it is sent
+	if sessionType == sessionSMS {
+		s.State = stateCallEnded
+		s.End = now
+		s.Volume = 1
+	}
+	s.stamp()
+	return s, t.putSession(stub, s)
+}
+
+// draftSession is the session of the given type rs would open at now,
+// rated as rs is at that moment
+func (t *SimpleChaincode) draftSession(stub shim.ChaincodeStubInterface, rs rsDetailBlock, sessionType string, destination string, now time.Time) sessionBlock {
+	s := sessionBlock{
+		Key:         rs.PublicKey,
+		Type:        sessionType,
+		Destination: destination,
+		RP:          rs.RP,
+		RateType:    rs.RateType,
+		Roaming:     rs.Roaming == "True",
+		State:       stateInCall,
+		Start:       now,
+	}
+	if z, ok := t.rlahZone(stub, rs.HO, rs.RP); ok && s.Roaming {
+		s.Zone = z.ID
+	}
+	return s
+}
+
+// unitPrice is what tariff charges per minute, MB or message of sessionType
+func unitPrice(tariff tariffBlock, sessionType string) float64 {
+	switch sessionType {
+	case sessionVoiceIn:
+		return tariff.VoiceInPerMin
+	case sessionData:
+		return tariff.DataPerMB
+	case sessionSMS:
+		return tariff.SMS
+	}
+	return tariff.VoicePerMin
+}
+
+// quantity is the minutes, MB or messages s is charged by
+func (s sessionBlock) quantity() float64 {
+	if s.Type == sessionVoice || s.Type == sessionVoiceIn {
+		return s.Duration
+	}
+	return s.Volume
+}
+
+// sessionCharge is what a session is charged for a quantity, and why
+type sessionCharge struct {
+	Tariff    tariffBlock
+	UnitPrice float64
+	Covered   float64
+	Surcharge float64
+	Charge    float64
+}
+
+// priceSession prices quantity of s with the tariff in force when it
+// started, its RateType. Usage is first taken out of the subscriber's plan
+// allowance and only the rest is charged. Data used in a Roam-Like-At-Home
+// zone also counts against the zone's fair use allowance, beyond which its
+// surcharge is added. The counters only move when record is set, so an
+// estimate is priced exactly as the session will be.
+func (t *SimpleChaincode) priceSession(stub shim.ChaincodeStubInterface, s sessionBlock, quantity float64, record bool) (sessionCharge, error) {
+	c := sessionCharge{Tariff: t.rates(stub, s.RateType)}
+	c.UnitPrice = unitPrice(c.Tariff, s.Type)
+	billable, err := t.useAllowance(stub, s, quantity, record)
+	if err != nil {
+		return sessionCharge{}, err
+	}
+	c.Covered = quantity - billable
+	c.Charge = billable * c.UnitPrice
+	if s.Type != sessionData || s.Zone == "" {
+		return c, nil
+	}
+	z, err := t.getZone(stub, s.Zone)
+	if err != nil {
+		return c, nil
+	}
+	excess, err := t.fairUseExcess(stub, s.Key, z, quantity, s.Start, record)
+	if err != nil {
+		return sessionCharge{}, err
+	}
+	c.Surcharge = excess * z.SurchargePerMB
+	c.Charge += c.Surcharge
+	return c, nil
+}
+
+// rateSession prices a finished session
+func (t *SimpleChaincode) rateSession(stub shim.ChaincodeStubInterface, s sessionBlock) (float64, error) {
+	c, err := t.priceSession(stub, s, s.quantity(), true)
+	return c.Charge, err
+}
+
+// settleSessions ends every unfinished session of rs at the given time and
+// charges it, returning the total charged
+func (t *SimpleChaincode) settleSessions(stub shim.ChaincodeStubInterface, rs rsDetailBlock, at time.Time) (float64, error) {
+	sessions, err := t.subscriberSessions(stub, rs.PublicKey)
+	if err != nil {
+		return 0, err
+	}
+	total := 0.0
+	for _, s := range sessions {
+		if s.State == stateCharged {
+			continue
+		}
+		if s.State == stateInCall {
+			s.End = at
+			s.Duration = at.Sub(s.Start).Minutes()
+		}
+		s.State = stateCharged
+		if s.Charges, err = t.rateSession(stub, s); err != nil {
+			return 0, err
+		}
+		total += s.Charges
+		if err = t.putSession(stub, s); err != nil {
+			return 0, err
+		}
+	}
+	return total, nil
+}
+
+//Data Start: open a data session
+func (t *SimpleChaincode) DataStart(stub shim.ChaincodeStubInterface, key string) ([]byte, error) {
+	return t.startSession(stub, key, sessionData, "")
+}
+
+//SMS Out: send an SMS, ready to be charged with CallPay
+func (t *SimpleChaincode) SMSOut(stub shim.ChaincodeStubInterface, key string, destmsisdn string) ([]byte, error) {
+	return t.startSession(stub, key, sessionSMS, destmsisdn)
+}
+
+//Query the call sessions of a subscriber
+func (t *SimpleChaincode) querySessions(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) < 1 {
+		return nil, errors.New("Incorrect number of arguments. Expecting key")
+	}
+	sessions, err := t.subscriberSessions(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if sessions == nil {
+		sessions = []sessionBlock{}
+	}
+	return json.Marshal(sessions)
+}
@@ -0,0 +1,219 @@
+package roaming
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Selected subscriber fields can be stored AES-GCM encrypted. The keys never
+// reach the ledger as arguments: the client passes them per request in the
+// transaction metadata, e.g.
+//
+//	{"fieldkey":"<base64 AES key>","indexkey":"<base64 HMAC key>","fields":["name","address"]}
+//
+// Chaincode must produce the same write set on every peer, so nonces are
+// derived rather than random: from the transaction ID for ordinary fields,
+// and from the plaintext itself for searchable fields such as msisdn, which
+// makes their ciphertext deterministic and usable as an index value.
+const sealedPrefix = "enc:"
+
+// defaultSealedFields are encrypted when the metadata does not list fields
+var defaultSealedFields = []string{"name", "address", "location", "lat", "long"}
+
+// searchableFields are encrypted deterministically
+var searchableFields = map[string]bool{"msisdn": true}
+
+type fieldKeys struct {
+	FieldKey []byte   `json:"fieldkey"`
+	IndexKey []byte   `json:"indexkey"`
+	Fields   []string `json:"fields"`
+}
+
+// readFieldKeys returns the keys supplied with the request, or nil if the
+// caller did not supply any
+func readFieldKeys(stub shim.ChaincodeStubInterface) (*fieldKeys, error) {
+	metadata, err := stub.GetCallerMetadata()
+	if err != nil {
+		return nil, fmt.Errorf("could not read field keys from metadata: %s", err)
+	}
+	if len(metadata) == 0 {
+		return nil, nil
+	}
+	var keys fieldKeys
+	if err = json.Unmarshal(metadata, &keys); err != nil {
+		return nil, fmt.Errorf("invalid field keys in metadata: %s", err)
+	}
+	switch len(keys.FieldKey) {
+	case 16, 24, 32:
+	default:
+		return nil, errors.New("field key must be 16, 24 or 32 bytes")
+	}
+	if len(keys.IndexKey) < 16 {
+		return nil, errors.New("index key must be at least 16 bytes")
+	}
+	if len(keys.Fields) == 0 {
+		keys.Fields = defaultSealedFields
+	}
+	for _, field := range keys.Fields {
+		if sealableField(&rsDetailBlock{}, field) == nil {
+			return nil, fmt.Errorf("field %q cannot be encrypted", field)
+		}
+	}
+	return &keys, nil
+}
+
+// sealableField returns a pointer to the named field of rs
+func sealableField(rs *rsDetailBlock, field string) *string {
+	switch field {
+	case "msisdn":
+		return &rs.MSISDN
+	case "name":
+		return &rs.Name
+	case "address":
+		return &rs.Address
+	case "location":
+		return &rs.Location
+	case "lat":
+		return &rs.Lat
+	case "long":
+		return &rs.Long
+	}
+	return nil
+}
+
+func isSealed(value string) bool {
+	return strings.HasPrefix(value, sealedPrefix)
+}
+
+func (k *fieldKeys) nonce(parts ...string) []byte {
+	mac := hmac.New(sha256.New, k.IndexKey)
+	for _, p := range parts {
+		mac.Write([]byte(p))
+		mac.Write([]byte{0})
+	}
+	return mac.Sum(nil)[:12]
+}
+
+func (k *fieldKeys) seal(nonce []byte, plaintext string) (string, error) {
+	block, err := aes.NewCipher(k.FieldKey)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(append([]byte{}, nonce...), nonce, []byte(plaintext), nil)
+	return sealedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (k *fieldKeys) open(value string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, sealedPrefix))
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(k.FieldKey)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	if len(raw) < gcm.NonceSize() {
+		return "", errors.New("sealed value too short")
+	}
+	plaintext, err := gcm.Open(nil, raw[:gcm.NonceSize()], raw[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("could not decrypt field, wrong key?")
+	}
+	return string(plaintext), nil
+}
+
+// searchable returns the stored form of a searchable field value, so that
+// lookups by e.g. MSISDN match what enterData indexed
+func (k *fieldKeys) searchable(field string, value string) (string, error) {
+	if k == nil || isSealed(value) {
+		return value, nil
+	}
+	for _, f := range k.Fields {
+		if f == field {
+			return k.seal(k.nonce(field, value), value)
+		}
+	}
+	return value, nil
+}
+
+// sealSubscriber encrypts the given fields of rs that are not yet encrypted
+// and records them in rs.Encrypted
+func sealSubscriber(stub shim.ChaincodeStubInterface, rs *rsDetailBlock, keys *fieldKeys, fields []string) error {
+	for _, field := range fields {
+		value := sealableField(rs, field)
+		if value == nil || isSealed(*value) {
+			continue
+		}
+		nonce := keys.nonce(stub.GetTxID(), rs.PublicKey, field)
+		if searchableFields[field] {
+			nonce = keys.nonce(field, *value)
+		}
+		sealed, err := keys.seal(nonce, *value)
+		if err != nil {
+			return err
+		}
+		*value = sealed
+		if !containsString(rs.Encrypted, field) {
+			rs.Encrypted = append(rs.Encrypted, field)
+		}
+	}
+	return nil
+}
+
+// openSubscriber decrypts every encrypted field of rs in place
+func openSubscriber(rs *rsDetailBlock, keys *fieldKeys) error {
+	for _, field := range rs.Encrypted {
+		value := sealableField(rs, field)
+		if value == nil || !isSealed(*value) {
+			continue
+		}
+		plaintext, err := keys.open(*value)
+		if err != nil {
+			return err
+		}
+		*value = plaintext
+	}
+	return nil
+}
+
+// resealSubscriber re-encrypts fields of an encrypted subscriber that a
+// handler has just overwritten with plaintext
+func resealSubscriber(stub shim.ChaincodeStubInterface, rs *rsDetailBlock) error {
+	if len(rs.Encrypted) == 0 {
+		return nil
+	}
+	keys, err := readFieldKeys(stub)
+	if err != nil {
+		return err
+	}
+	if keys == nil {
+		return fmt.Errorf("subscriber %s has encrypted fields, field keys are required", rs.PublicKey)
+	}
+	return sealSubscriber(stub, rs, keys, rs.Encrypted)
+}
+
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package roaming
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+func testFieldKeys(fields ...string) *fieldKeys {
+	return &fieldKeys{
+		FieldKey: []byte("0123456789abcdef"),
+		IndexKey: []byte("fedcba9876543210"),
+		Fields:   fields,
+	}
+}
+
+func TestSealOpen(t *testing.T) {
+	keys := testFieldKeys()
+	sealed, err := keys.seal(keys.nonce("tx1", "rs1", "name"), "Ann Smith")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isSealed(sealed) || strings.Contains(sealed, "Ann") {
+		t.Fatalf("seal returned %q", sealed)
+	}
+	plaintext, err := keys.open(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext != "Ann Smith" {
+		t.Errorf("open returned %q, want Ann Smith", plaintext)
+	}
+	// nonces from different transactions give different ciphertexts
+	again, _ := keys.seal(keys.nonce("tx2", "rs1", "name"), "Ann Smith")
+	if again == sealed {
+		t.Error("sealing in another transaction gave the same ciphertext")
+	}
+}
+
+func TestOpenRejects(t *testing.T) {
+	keys := testFieldKeys()
+	sealed, _ := keys.seal(keys.nonce("tx1"), "Ann Smith")
+
+	wrong := testFieldKeys()
+	wrong.FieldKey = []byte("abcdef0123456789")
+	if _, err := wrong.open(sealed); err == nil {
+		t.Error("opened a value sealed with another key")
+	}
+	tampered := sealed[:len(sealed)-4] + "AAA="
+	if _, err := keys.open(tampered); err == nil {
+		t.Error("opened a tampered value")
+	}
+	if _, err := keys.open(sealedPrefix + "AAAA"); err == nil {
+		t.Error("opened a value shorter than its nonce")
+	}
+	if _, err := keys.open(sealedPrefix + "not base64!"); err == nil {
+		t.Error("opened a value that is not base64")
+	}
+}
+
+func TestSearchable(t *testing.T) {
+	keys := testFieldKeys("msisdn")
+	a, err := keys.searchable("msisdn", "14691234567")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := keys.searchable("msisdn", "14691234567")
+	if a != b || !isSealed(a) {
+		t.Errorf("searchable is not deterministic: %q, %q", a, b)
+	}
+	if c, _ := keys.searchable("msisdn", "14691234568"); c == a {
+		t.Error("different MSISDNs have the same searchable form")
+	}
+	if again, _ := keys.searchable("msisdn", a); again != a {
+		t.Error("searchable sealed an already sealed value")
+	}
+	if plain, _ := keys.searchable("name", "Ann"); plain != "Ann" {
+		t.Errorf("a field not listed was sealed: %q", plain)
+	}
+	var none *fieldKeys
+	if plain, _ := none.searchable("msisdn", "14691234567"); plain != "14691234567" {
+		t.Errorf("searchable without keys returned %q", plain)
+	}
+}
+
+func TestSealOpenSubscriber(t *testing.T) {
+	stub := shim.NewMockStub("crypto", nil)
+	stub.MockTransactionStart("tx1")
+	defer stub.MockTransactionEnd("tx1")
+
+	keys := testFieldKeys("msisdn", "name")
+	rs := rsDetailBlock{PublicKey: "rs1", MSISDN: "14691234567", Name: "Ann Smith", Address: "DALLAS"}
+	if err := sealSubscriber(stub, &rs, keys, keys.Fields); err != nil {
+		t.Fatal(err)
+	}
+	if !isSealed(rs.MSISDN) || !isSealed(rs.Name) || rs.Address != "DALLAS" {
+		t.Fatalf("sealed subscriber is %+v", rs)
+	}
+	if len(rs.Encrypted) != 2 {
+		t.Errorf("Encrypted = %v, want msisdn and name", rs.Encrypted)
+	}
+	// the MSISDN is stored in its searchable form, so it can be indexed
+	if indexed, _ := keys.searchable("msisdn", "14691234567"); rs.MSISDN != indexed {
+		t.Errorf("sealed MSISDN %q differs from its searchable form %q", rs.MSISDN, indexed)
+	}
+	if err := sealSubscriber(stub, &rs, keys, keys.Fields); err != nil || len(rs.Encrypted) != 2 {
+		t.Errorf("sealing twice gave %v, %v", rs.Encrypted, err)
+	}
+	if err := openSubscriber(&rs, keys); err != nil {
+		t.Fatal(err)
+	}
+	if rs.MSISDN != "14691234567" || rs.Name != "Ann Smith" {
+		t.Errorf("opened subscriber is %+v", rs)
+	}
+}
@@ -0,0 +1,123 @@
+package roaming
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Where an estimate found its tariff
+const (
+	tariffFromRLAH      = "rlah"
+	tariffFromAgreement = "agreement"
+	tariffFromRateType  = "ratetype"
+	tariffFromDefault   = "default"
+)
+
+// sessionUnits is what a session of each type is measured in
+var sessionUnits = map[string]string{
+	sessionVoice:   "min",
+	sessionVoiceIn: "min",
+	sessionData:    "MB",
+	sessionSMS:     "SMS",
+}
+
+// chargeEstimate itemizes what a session would be charged if it were
+// started now. Covered is the part of Quantity left in the subscriber's
+// plan allowance. Nothing is written to the ledger to produce it.
+type chargeEstimate struct {
+	Key          string    `json:"key"`
+	HO           string    `json:"ho"`
+	RP           string    `json:"rp"`
+	Roaming      string    `json:"roaming"`
+	Type         string    `json:"type"`
+	Destination  string    `json:"destination"`
+	Tariff       string    `json:"tariff"`
+	TariffSource string    `json:"tariffsource"`
+	Currency     string    `json:"currency"`
+	Quantity     float64   `json:"quantity"`
+	Unit         string    `json:"unit"`
+	Covered      float64   `json:"covered"`
+	UnitPrice    float64   `json:"unitprice"`
+	Zone         string    `json:"zone,omitempty"`
+	Surcharge    float64   `json:"surcharge,omitempty"`
+	Charge       float64   `json:"charge"`
+	At           time.Time `json:"at"`
+}
+
+// tariffSource tells where the tariff s is rated with comes from: the
+// domestic tariff of rs's HO in a Roam-Like-At-Home zone, the agreement
+// between its HO and RP, another RateType, or none, for the default prices
+func (t *SimpleChaincode) tariffSource(stub shim.ChaincodeStubInterface, rs rsDetailBlock, s sessionBlock, tariff tariffBlock) string {
+	if s.RateType == "" || tariff.ID != s.RateType {
+		return tariffFromDefault
+	}
+	if s.Zone != "" && s.RateType == t.domesticTariff(stub, rs.HO) {
+		return tariffFromRLAH
+	}
+	if s.Roaming {
+		if agreement, err := t.getAgreement(stub, rs.HO, s.RP, s.Start); err == nil && agreement.Tariff == s.RateType {
+			return tariffFromAgreement
+		}
+	}
+	return tariffFromRateType
+}
+
+//Estimate the charge of a session before it is started
+//args: key, destination, duration in minutes (MB for data, messages for
+//SMS), optional session type voice, voicein, data or sms (default voice)
+func (t *SimpleChaincode) estimateCharge(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) < 3 {
+		return nil, errors.New("Incorrect number of arguments. Expecting key, destination, duration and optional session type")
+	}
+	sessionType := sessionVoice
+	if len(args) > 3 && args[3] != "" {
+		sessionType = args[3]
+	}
+	unit, ok := sessionUnits[sessionType]
+	if !ok {
+		return nil, fmt.Errorf("unknown session type %q", sessionType)
+	}
+	quantity, err := strconv.ParseFloat(args[2], 64)
+	if err != nil || quantity < 0 {
+		return nil, errors.New("duration must be a non-negative number")
+	}
+	rs, err := t.getSubscriber(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	//Priced exactly as the session it would start now will be rated, at the
+	//tariff updateRates resolves even if it has not run since discovery
+	now := txTime(stub)
+	rs.RateType = t.roamingRateType(stub, rs, now)
+	s := t.draftSession(stub, rs, sessionType, args[1], now)
+	c, err := t.priceSession(stub, s, quantity, false)
+	if err != nil {
+		return nil, err
+	}
+	estimate := chargeEstimate{
+		Key:          rs.PublicKey,
+		HO:           rs.HO,
+		RP:           rs.RP,
+		Roaming:      rs.Roaming,
+		Type:         sessionType,
+		Destination:  s.Destination,
+		Tariff:       c.Tariff.ID,
+		TariffSource: t.tariffSource(stub, rs, s, c.Tariff),
+		Currency:     c.Tariff.Currency,
+		Quantity:     quantity,
+		Unit:         unit,
+		Covered:      c.Covered,
+		UnitPrice:    c.UnitPrice,
+		Zone:         s.Zone,
+		Surcharge:    c.Surcharge,
+		Charge:       c.Charge,
+		At:           s.Start,
+	}
+	return json.Marshal(estimate)
+}
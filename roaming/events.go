@@ -0,0 +1,116 @@
+package roaming
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// eventSchemaVersion is bumped whenever roamingEvent changes in a way
+// listeners need to know about
+const eventSchemaVersion = 2
+
+// Roaming event types. Each is also used as the chaincode event name so
+// listeners can register for just the changes they care about.
+const (
+	eventDiscovered    = "Discovered"
+	eventAuthenticated = "Authenticated"
+	eventAuthRejected  = "AuthRejected"
+	eventFraudFlagged  = "FraudFlagged"
+	eventRatesUpdated  = "RatesUpdated"
+	eventCallStarted   = "CallStarted"
+	eventCallEnded     = "CallEnded"
+	eventCallCharged   = "CallCharged"
+	eventOverage       = "Overage"
+	eventSteered       = "Steered"
+)
+
+// roamingEvent is the payload of every roaming chaincode event.
+// Listeners should check Version before relying on the other fields.
+type roamingEvent struct {
+	Version     int       `json:"version"`
+	Type        string    `json:"type"`
+	Key         string    `json:"key"`
+	MSISDN      string    `json:"msisdn"`
+	HO          string    `json:"ho"`
+	RP          string    `json:"rp"`
+	Roaming     string    `json:"roaming"`
+	State       string    `json:"state"`
+	RateType    string    `json:"ratetype"`
+	SessionID   string    `json:"sessionid,omitempty"`
+	Destination string    `json:"destination"`
+	Duration    float64   `json:"duration"`
+	Charges     float64   `json:"charges"`
+	Flag        string    `json:"flag"`
+	TxID        string    `json:"txid"`
+	Timestamp   time.Time `json:"timestamp"`
+	//Since version 2
+	Notifications []notification `json:"notifications,omitempty"`
+}
+
+// txTime returns the transaction timestamp, falling back to the local clock
+// when the stub does not carry one
+func txTime(stub shim.ChaincodeStubInterface) time.Time {
+	ts, err := stub.GetTxTimestamp()
+	if err != nil || ts == nil {
+		return time.Now().UTC()
+	}
+	return time.Unix(ts.Seconds, int64(ts.Nanos)).UTC()
+}
+
+// emitRoamingEvent publishes the state of rs after a roaming state change,
+// with any notifications the change raised. A transaction carries a single
+// event, so notifications cannot be events of their own.
+func emitRoamingEvent(stub shim.ChaincodeStubInterface, eventType string, rs rsDetailBlock, notes ...notification) error {
+	event := newRoamingEvent(stub, eventType, rs)
+	event.Notifications = notes
+	return publishEvent(stub, event)
+}
+
+// emitSteeredEvent publishes an attach to rp turned away by steering. RP is
+// the partner that was refused; the subscriber record is unchanged.
+func emitSteeredEvent(stub shim.ChaincodeStubInterface, rs rsDetailBlock, rp string) error {
+	event := newRoamingEvent(stub, eventSteered, rs)
+	event.RP = rp
+	return publishEvent(stub, event)
+}
+
+// emitSessionEvent publishes a change to one of rs's sessions
+func emitSessionEvent(stub shim.ChaincodeStubInterface, eventType string, rs rsDetailBlock, s sessionBlock, notes ...notification) error {
+	event := newRoamingEvent(stub, eventType, rs)
+	event.Notifications = notes
+	event.SessionID = s.ID
+	event.Destination = s.Destination
+	event.Duration = s.Duration
+	event.Charges = s.Charges
+	return publishEvent(stub, event)
+}
+
+func newRoamingEvent(stub shim.ChaincodeStubInterface, eventType string, rs rsDetailBlock) roamingEvent {
+	return roamingEvent{
+		Version:     eventSchemaVersion,
+		Type:        eventType,
+		Key:         rs.PublicKey,
+		MSISDN:      rs.MSISDN,
+		HO:          rs.HO,
+		RP:          rs.RP,
+		Roaming:     rs.Roaming,
+		State:       sessionState(rs),
+		RateType:    rs.RateType,
+		Destination: rs.Destination,
+		Duration:    rs.Duration,
+		Charges:     rs.Charges,
+		Flag:        rs.Flag,
+		TxID:        stub.GetTxID(),
+		Timestamp:   txTime(stub),
+	}
+}
+
+func publishEvent(stub shim.ChaincodeStubInterface, event roamingEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return stub.SetEvent(event.Type, payload)
+}
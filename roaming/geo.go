@@ -0,0 +1,257 @@
+package roaming
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+const earthRadiusKm = 6371.0
+
+// Subscribers with a position are indexed by the geohash of it, so an area
+// can be searched by scanning the geohash cells covering it:
+//
+//	\x00geo\x00<geohash>\x00<PublicKey>\x00 -> empty value
+//
+// A geohash names a cell; each further character divides it in 32, and a
+// position's geohash starts with those of every larger cell containing it.
+const (
+	geoIndex         = "geo"
+	geohashPrecision = 9
+	geohashAlphabet  = "0123456789bcdefghjkmnpqrstuvwxyz"
+	// maxGeoCells caps the cells scanned for one area, which is searched at
+	// the finest precision covering it with no more cells than this
+	maxGeoCells = 64
+)
+
+// geoPoint is a position in degrees and its geohash
+type geoPoint struct {
+	Lat     float64 `json:"lat"`
+	Long    float64 `json:"long"`
+	Geohash string  `json:"geohash"`
+}
+
+// distanceKm is the great circle distance between two coordinates in degrees
+func distanceKm(lat1, long1, lat2, long2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLong := (long2 - long1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLong/2)*math.Sin(dLong/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+// covers returns the first area of op containing the coordinates
+func (op operatorBlock) covers(lat, long float64) (coverageArea, bool) {
+	for _, area := range op.Coverage {
+		if distanceKm(area.Lat, area.Long, lat, long) <= area.RadiusKm {
+			return area, true
+		}
+	}
+	return coverageArea{}, false
+}
+
+// parsePoint reads coordinates given as strings and checks their range
+func parsePoint(lat string, long string) (geoPoint, error) {
+	la, err := strconv.ParseFloat(lat, 64)
+	if err != nil {
+		return geoPoint{}, fmt.Errorf("coordinate %s is not a number", lat)
+	}
+	lo, err := strconv.ParseFloat(long, 64)
+	if err != nil {
+		return geoPoint{}, fmt.Errorf("coordinate %s is not a number", long)
+	}
+	if la < -90 || la > 90 || lo < -180 || lo > 180 {
+		return geoPoint{}, fmt.Errorf("coordinates %s, %s are out of range", lat, long)
+	}
+	return geoPoint{Lat: la, Long: lo, Geohash: encodeGeohash(la, lo, geohashPrecision)}, nil
+}
+
+// checkCoordinates validates coordinates as given to enterData or
+// discoverRP, where either may be left empty
+func checkCoordinates(lat string, long string) error {
+	for _, coord := range []string{lat, long} {
+		if _, err := strconv.ParseFloat(coord, 64); coord != "" && err != nil {
+			return fmt.Errorf("coordinate %s is not a number", coord)
+		}
+	}
+	if lat == "" || long == "" {
+		return nil
+	}
+	_, err := parsePoint(lat, long)
+	return err
+}
+
+// locate sets the numeric position of rs from its Lat and Long. Subscribers
+// whose coordinates are missing or encrypted have none, and are left out of
+// the geohash index.
+func locate(rs *rsDetailBlock) error {
+	rs.Position = nil
+	if rs.Lat == "" || rs.Long == "" || isSealed(rs.Lat) || isSealed(rs.Long) {
+		return nil
+	}
+	p, err := parsePoint(rs.Lat, rs.Long)
+	if err != nil {
+		return err
+	}
+	rs.Position = &p
+	return nil
+}
+
+// geohash returns the geohash rs is indexed under, or "" if it is not
+func (rs rsDetailBlock) geohash() string {
+	if rs.Position == nil {
+		return ""
+	}
+	return rs.Position.Geohash
+}
+
+// encodeGeohash returns the geohash of precision characters containing the
+// coordinates
+func encodeGeohash(lat, long float64, precision int) string {
+	latRange := [2]float64{-90, 90}
+	longRange := [2]float64{-180, 180}
+	hash := make([]byte, 0, precision)
+	bit, ch, even := 0, 0, true
+	for len(hash) < precision {
+		r, v := &latRange, lat
+		if even {
+			r, v = &longRange, long
+		}
+		mid := (r[0] + r[1]) / 2
+		ch <<= 1
+		if v >= mid {
+			ch |= 1
+			r[0] = mid
+		} else {
+			r[1] = mid
+		}
+		even = !even
+		if bit++; bit == 5 {
+			hash = append(hash, geohashAlphabet[ch])
+			bit, ch = 0, 0
+		}
+	}
+	return string(hash)
+}
+
+// geohashCellSize returns the height and width in degrees of the cells of
+// a precision
+func geohashCellSize(precision int) (float64, float64) {
+	bits := 5 * precision
+	longBits := (bits + 1) / 2
+	latBits := bits / 2
+	return 180 / math.Pow(2, float64(latBits)), 360 / math.Pow(2, float64(longBits))
+}
+
+// geoBox is an area between two latitudes and two longitudes. A box
+// crossing the antimeridian has MinLong > MaxLong.
+type geoBox struct {
+	MinLat  float64 `json:"minlat"`
+	MinLong float64 `json:"minlong"`
+	MaxLat  float64 `json:"maxlat"`
+	MaxLong float64 `json:"maxlong"`
+}
+
+func (b geoBox) contains(lat, long float64) bool {
+	if lat < b.MinLat || lat > b.MaxLat {
+		return false
+	}
+	if b.MinLong <= b.MaxLong {
+		return long >= b.MinLong && long <= b.MaxLong
+	}
+	return long >= b.MinLong || long <= b.MaxLong
+}
+
+// split returns b as boxes that do not cross the antimeridian
+func (b geoBox) split() []geoBox {
+	if b.MinLong <= b.MaxLong {
+		return []geoBox{b}
+	}
+	return []geoBox{
+		{MinLat: b.MinLat, MinLong: b.MinLong, MaxLat: b.MaxLat, MaxLong: 180},
+		{MinLat: b.MinLat, MinLong: -180, MaxLat: b.MaxLat, MaxLong: b.MaxLong},
+	}
+}
+
+// radiusBox is the box enclosing the circle of km around a position. The
+// meridians tangent to the circle are asin(sin(d)/cos(lat)) either side of
+// its centre, d being its angular radius; they touch it poleward of the
+// centre, where it is wider than d/cos(lat)
+func radiusBox(lat, long, km float64) geoBox {
+	d := km / earthRadiusKm
+	dLat := d * 180 / math.Pi
+	box := geoBox{MinLat: math.Max(lat-dLat, -90), MaxLat: math.Min(lat+dLat, 90), MinLong: -180, MaxLong: 180}
+	ratio := math.Sin(d) / math.Cos(lat*math.Pi/180)
+	if box.MinLat == -90 || box.MaxLat == 90 || d >= math.Pi/2 || ratio >= 1 {
+		//Reaches a pole: every longitude
+		return box
+	}
+	dLong := math.Asin(ratio) * 180 / math.Pi
+	box.MinLong = long - dLong
+	box.MaxLong = long + dLong
+	if box.MinLong < -180 {
+		box.MinLong += 360
+	}
+	if box.MaxLong > 180 {
+		box.MaxLong -= 360
+	}
+	return box
+}
+
+// coverCells returns geohash cells that together cover b, at the finest
+// precision needing no more than maxGeoCells of them
+func coverCells(b geoBox) []string {
+	boxes := b.split()
+	for precision := geohashPrecision; precision > 1; precision-- {
+		height, width := geohashCellSize(precision)
+		count := 0.0
+		for _, box := range boxes {
+			count += (math.Floor((box.MaxLat-box.MinLat)/height) + 2) * (math.Floor((box.MaxLong-box.MinLong)/width) + 2)
+		}
+		if count <= maxGeoCells {
+			return boxCells(boxes, precision)
+		}
+	}
+	return boxCells(boxes, 1)
+}
+
+// boxCells lists the cells of a precision touching boxes, stepping over
+// each box one cell at a time and including its far edges
+func boxCells(boxes []geoBox, precision int) []string {
+	height, width := geohashCellSize(precision)
+	seen := map[string]bool{}
+	var cells []string
+	for _, box := range boxes {
+		for lat := box.MinLat; ; lat = math.Min(lat+height, box.MaxLat) {
+			for long := box.MinLong; ; long = math.Min(long+width, box.MaxLong) {
+				cell := encodeGeohash(lat, long, precision)
+				if !seen[cell] {
+					seen[cell] = true
+					cells = append(cells, cell)
+				}
+				if long >= box.MaxLong {
+					break
+				}
+			}
+			if lat >= box.MaxLat {
+				break
+			}
+		}
+	}
+	return cells
+}
+
+// reindexPosition moves key in the geo index from the cell of oldHash to
+// that of newHash
+func reindexPosition(stub shim.ChaincodeStubInterface, key string, oldHash string, newHash string) error {
+	if oldHash == newHash {
+		return nil
+	}
+	if err := delIndex(stub, geoIndex, oldHash, key); err != nil {
+		return err
+	}
+	return putIndex(stub, geoIndex, newHash, key)
+}
@@ -0,0 +1,145 @@
+package roaming
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEncodeGeohash(t *testing.T) {
+	tests := []struct {
+		lat, long float64
+		want      string
+	}{
+		{57.64911, 10.40744, "u4pruydqq"},
+		{41.3851, 2.1734, "sp3e3myte"},
+		{0, 0, "s00000000"},
+	}
+	for _, tt := range tests {
+		if got := encodeGeohash(tt.lat, tt.long, geohashPrecision); got != tt.want {
+			t.Errorf("encodeGeohash(%v, %v) = %s, want %s", tt.lat, tt.long, got, tt.want)
+		}
+	}
+}
+
+func TestGeohashCellSize(t *testing.T) {
+	height, width := geohashCellSize(1)
+	if height != 45 || width != 45 {
+		t.Errorf("precision 1 cells are %v x %v, want 45 x 45", height, width)
+	}
+	height, width = geohashCellSize(2)
+	if height != 5.625 || width != 11.25 {
+		t.Errorf("precision 2 cells are %v x %v, want 5.625 x 11.25", height, width)
+	}
+}
+
+// destination is the point km away from lat, long on bearing degrees
+func destination(lat, long, km, bearing float64) (float64, float64) {
+	rad := math.Pi / 180
+	d := km / earthRadiusKm
+	la, lo, b := lat*rad, long*rad, bearing*rad
+	la2 := math.Asin(math.Sin(la)*math.Cos(d) + math.Cos(la)*math.Sin(d)*math.Cos(b))
+	lo2 := lo + math.Atan2(math.Sin(b)*math.Sin(d)*math.Cos(la), math.Cos(d)-math.Sin(la)*math.Sin(la2))
+	lo2 = math.Mod(lo2/rad+540, 360) - 180
+	return la2 / rad, lo2
+}
+
+func TestRadiusBoxEnclosesCircle(t *testing.T) {
+	tests := []struct {
+		lat, long, km float64
+	}{
+		{41.3851, 2.1734, 50},
+		{60, 10, 1500},
+		{65, 25, 2000},
+		{-45, 179.5, 300},
+		{0, -179.9, 100},
+	}
+	for _, tt := range tests {
+		box := radiusBox(tt.lat, tt.long, tt.km)
+		for bearing := 0.0; bearing < 360; bearing += 0.5 {
+			// a hair inside the circle, so rounding cannot put it outside
+			lat, long := destination(tt.lat, tt.long, tt.km*0.999999, bearing)
+			if !box.contains(lat, long) {
+				t.Errorf("radiusBox(%v, %v, %v) = %+v leaves out %v, %v at bearing %v",
+					tt.lat, tt.long, tt.km, box, lat, long, bearing)
+				break
+			}
+		}
+	}
+}
+
+func TestRadiusBoxWidth(t *testing.T) {
+	// the tangent meridians, not the ones due east and west, bound the circle
+	box := radiusBox(60, 25, 1500)
+	d := 1500 / earthRadiusKm
+	want := math.Asin(math.Sin(d)/math.Cos(60*math.Pi/180)) * 180 / math.Pi
+	if got := box.MaxLong - 25; math.Abs(got-want) > 1e-9 {
+		t.Errorf("half width is %v degrees, want %v", got, want)
+	}
+	if got := 25 - box.MinLong; math.Abs(got-want) > 1e-9 {
+		t.Errorf("half width west is %v degrees, want %v", got, want)
+	}
+}
+
+func TestRadiusBoxPoles(t *testing.T) {
+	for _, tt := range []struct{ lat, km float64 }{{89.9, 50}, {-89, 500}, {10, 11000}} {
+		box := radiusBox(tt.lat, 0, tt.km)
+		if box.MinLong != -180 || box.MaxLong != 180 {
+			t.Errorf("radiusBox(%v, 0, %v) = %+v, want every longitude", tt.lat, tt.km, box)
+		}
+	}
+}
+
+func TestRadiusBoxAntimeridian(t *testing.T) {
+	box := radiusBox(0, 179.9, 100)
+	if box.MinLong <= box.MaxLong {
+		t.Fatalf("radiusBox(0, 179.9, 100) = %+v, want a box crossing the antimeridian", box)
+	}
+	if len(box.split()) != 2 {
+		t.Errorf("%+v splits into %d boxes, want 2", box, len(box.split()))
+	}
+}
+
+func TestCoverCells(t *testing.T) {
+	boxes := []geoBox{
+		{MinLat: 41.3, MinLong: 2.1, MaxLat: 41.5, MaxLong: 2.3},
+		{MinLat: 35, MinLong: -10, MaxLat: 60, MaxLong: 20},
+		{MinLat: -20, MinLong: 170, MaxLat: -10, MaxLong: -170},
+		radiusBox(41.3851, 2.1734, 50),
+		{MinLat: -90, MinLong: -180, MaxLat: 90, MaxLong: 180},
+	}
+	for _, box := range boxes {
+		cells := coverCells(box)
+		if len(cells) == 0 || len(cells) > maxGeoCells {
+			t.Errorf("coverCells(%+v) returned %d cells", box, len(cells))
+			continue
+		}
+		// every position in the box must fall in one of the cells
+		for i := 0; i <= 10; i++ {
+			for j := 0; j <= 10; j++ {
+				lat := box.MinLat + (box.MaxLat-box.MinLat)*float64(i)/10
+				width := box.MaxLong - box.MinLong
+				if width < 0 {
+					width += 360
+				}
+				long := box.MinLong + width*float64(j)/10
+				if long > 180 {
+					long -= 360
+				}
+				hash := encodeGeohash(lat, long, geohashPrecision)
+				if !coveredBy(hash, cells) {
+					t.Errorf("coverCells(%+v) misses %v, %v (%s)", box, lat, long, hash)
+				}
+			}
+		}
+	}
+}
+
+func coveredBy(hash string, cells []string) bool {
+	for _, cell := range cells {
+		if strings.HasPrefix(hash, cell) {
+			return true
+		}
+	}
+	return false
+}
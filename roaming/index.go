@@ -0,0 +1,122 @@
+package roaming
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Subscribers are stored under their PublicKey. The msisdn index maps the
+// number operators actually know back to that key:
+//
+//	\x00msisdn\x00<MSISDN>\x00<PublicKey>\x00 -> empty value
+//
+// Terminated subscribers keep their index entry so their records stay
+// reachable for audit, but only one non-terminated subscriber may hold an
+// MSISDN at a time.
+const msisdnIndex = "msisdn"
+
+// indexKeys returns the keys of every entry under objectType whose leading
+// attributes equal attributes
+func indexKeys(stub shim.ChaincodeStubInterface, objectType string, attributes []string) ([]string, error) {
+	prefix, err := createCompositeKey(objectType, attributes)
+	if err != nil {
+		return nil, err
+	}
+	iter, err := stub.RangeQueryState(prefix, prefix+maxUnicodeRune)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var keys []string
+	for iter.HasNext() {
+		indexKey, _, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, parts, err := splitCompositeKey(indexKey)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, parts[len(parts)-1])
+	}
+	return keys, nil
+}
+
+// putIndex records that key is reachable through value in the given index
+func putIndex(stub shim.ChaincodeStubInterface, index string, value string, key string) error {
+	if value == "" {
+		return nil
+	}
+	indexKey, err := createCompositeKey(index, []string{value, key})
+	if err != nil {
+		return err
+	}
+	return stub.PutState(indexKey, []byte{0x00})
+}
+
+// delIndex removes an entry written by putIndex
+func delIndex(stub shim.ChaincodeStubInterface, index string, value string, key string) error {
+	if value == "" {
+		return nil
+	}
+	indexKey, err := createCompositeKey(index, []string{value, key})
+	if err != nil {
+		return err
+	}
+	return stub.DelState(indexKey)
+}
+
+// activeHolder returns the key of the non-terminated subscriber holding
+// msisdn, or "" if there is none
+func (t *SimpleChaincode) activeHolder(stub shim.ChaincodeStubInterface, msisdn string) (string, error) {
+	keys, err := indexKeys(stub, msisdnIndex, []string{msisdn})
+	if err != nil {
+		return "", err
+	}
+	for _, key := range keys {
+		rs, err := t.getSubscriber(stub, key)
+		if err != nil {
+			return "", err
+		}
+		if rs.MSISDN == msisdn && subscriberStatus(rs) != statusTerminated {
+			return key, nil
+		}
+	}
+	return "", nil
+}
+
+//Query a subscriber by MSISDN rather than by PublicKey
+func (t *SimpleChaincode) queryByMSISDN(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) < 1 {
+		return nil, errors.New("Incorrect number of arguments. Expecting MSISDN")
+	}
+	keys, err := readFieldKeys(stub)
+	if err != nil {
+		return nil, err
+	}
+	msisdn, err := keys.searchable("msisdn", args[0])
+	if err != nil {
+		return nil, err
+	}
+	key, err := t.activeHolder(stub, msisdn)
+	if err != nil {
+		return nil, err
+	}
+	if key == "" {
+		return nil, fmt.Errorf("no active subscriber holds MSISDN %s", args[0])
+	}
+	rs, err := t.getSubscriber(stub, key)
+	if err != nil {
+		return nil, err
+	}
+	if keys != nil {
+		if err = openSubscriber(&rs, keys); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(rs)
+}
@@ -0,0 +1,50 @@
+package roaming
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// Secondary records (indexes, porting records, ...) are stored under
+// composite keys laid out the same way as Fabric's CreateCompositeKey:
+// a leading NUL, the object type and each attribute, each terminated by NUL.
+// The leading NUL keeps them out of the plain subscriber key space.
+const (
+	compositeKeyNamespace = "\x00"
+	maxUnicodeRune        = string(utf8.MaxRune)
+)
+
+// createCompositeKey joins objectType and attributes into a composite key
+func createCompositeKey(objectType string, attributes []string) (string, error) {
+	if err := validateKeyPart(objectType); err != nil {
+		return "", err
+	}
+	ck := compositeKeyNamespace + objectType + compositeKeyNamespace
+	for _, att := range attributes {
+		if err := validateKeyPart(att); err != nil {
+			return "", err
+		}
+		ck += att + compositeKeyNamespace
+	}
+	return ck, nil
+}
+
+// splitCompositeKey is the inverse of createCompositeKey
+func splitCompositeKey(compositeKey string) (string, []string, error) {
+	if !strings.HasPrefix(compositeKey, compositeKeyNamespace) {
+		return "", nil, errors.New("not a composite key: " + compositeKey)
+	}
+	parts := strings.Split(compositeKey[1:len(compositeKey)-1], compositeKeyNamespace)
+	return parts[0], parts[1:], nil
+}
+
+func validateKeyPart(part string) error {
+	if !utf8.ValidString(part) {
+		return errors.New("key part is not valid utf-8: " + part)
+	}
+	if strings.ContainsAny(part, compositeKeyNamespace+maxUnicodeRune) {
+		return errors.New("key part contains a reserved character: " + part)
+	}
+	return nil
+}
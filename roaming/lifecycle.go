@@ -0,0 +1,172 @@
+package roaming
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Subscriber lifecycle states. Records written before lifecycle states were
+// introduced carry an empty Status and are treated as active.
+const (
+	statusActive     = "ACTIVE"
+	statusSuspended  = "SUSPENDED"
+	statusBarred     = "BARRED"
+	statusTerminated = "TERMINATED"
+)
+
+// lifecycleTransitions lists the states a subscriber may be moved to from
+// each state. Terminated is final: the record is kept for audit only.
+var lifecycleTransitions = map[string][]string{
+	statusActive:     {statusSuspended, statusBarred, statusTerminated},
+	statusSuspended:  {statusActive, statusBarred, statusTerminated},
+	statusBarred:     {statusActive, statusSuspended, statusTerminated},
+	statusTerminated: {},
+}
+
+// statusFunctions is the Invoke function moving a subscriber to each status
+var statusFunctions = map[string]string{
+	statusActive:     "reactivateSubscriber",
+	statusSuspended:  "suspendSubscriber",
+	statusBarred:     "barSubscriber",
+	statusTerminated: "terminateSubscriber",
+}
+
+// statusChange is one entry of a subscriber's lifecycle audit trail
+type statusChange struct {
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	Reason string    `json:"reason"`
+	By     string    `json:"by"`
+	Time   time.Time `json:"time"`
+}
+
+// subscriberStatus returns the lifecycle state of rs, defaulting to active
+func subscriberStatus(rs rsDetailBlock) string {
+	if rs.Status == "" {
+		return statusActive
+	}
+	return rs.Status
+}
+
+// checkActive rejects subscribers that may not roam or place calls
+func checkActive(rs rsDetailBlock) error {
+	status := subscriberStatus(rs)
+	if status != statusActive {
+		return fmt.Errorf("subscriber %s is %s", rs.PublicKey, status)
+	}
+	if rs.SIMState != "" && rs.SIMState != simActive {
+		return fmt.Errorf("SIM of subscriber %s is %s", rs.PublicKey, rs.SIMState)
+	}
+	return nil
+}
+
+// callerOperator returns the operator invoking. A certificate's "mspid"
+// attribute is mapped to the operator registered with that MSP; otherwise
+// its "operator" attribute names the operator. A certificate carrying both
+// must agree on them.
+func callerOperator(stub shim.ChaincodeStubInterface) (string, error) {
+	operator, err := stub.ReadCertAttribute("operator")
+	if err != nil {
+		return "", fmt.Errorf("could not read caller operator: %s", err)
+	}
+	msp, err := stub.ReadCertAttribute("mspid")
+	if err != nil {
+		return "", fmt.Errorf("could not read caller MSP: %s", err)
+	}
+	if len(msp) > 0 {
+		names, err := indexKeys(stub, operatorMSPIndex, []string{string(msp)})
+		if err != nil {
+			return "", err
+		}
+		if len(names) == 0 {
+			return "", fmt.Errorf("MSP %s is not registered to an operator", msp)
+		}
+		if len(operator) > 0 && string(operator) != names[0] {
+			return "", fmt.Errorf("caller operator %s does not match MSP %s of %s", operator, msp, names[0])
+		}
+		return names[0], nil
+	}
+	if len(operator) == 0 {
+		return "", errors.New("caller certificate has no operator or mspid attribute")
+	}
+	return string(operator), nil
+}
+
+// callerIsAdmin rejects callers whose certificate lacks the "role" attribute
+// "admin", which chaincode-wide settings and maintenance require
+func callerIsAdmin(stub shim.ChaincodeStubInterface) error {
+	role, err := stub.ReadCertAttribute("role")
+	if err != nil || string(role) != "admin" {
+		return errors.New("caller is not a chaincode administrator")
+	}
+	return nil
+}
+
+//Suspend a subscriber, e.g. for non-payment
+func (t *SimpleChaincode) suspendSubscriber(stub shim.ChaincodeStubInterface, key string, reason string) ([]byte, error) {
+	return t.changeStatus(stub, key, statusSuspended, reason)
+}
+
+//Bar a subscriber, e.g. on a fraud investigation
+func (t *SimpleChaincode) barSubscriber(stub shim.ChaincodeStubInterface, key string, reason string) ([]byte, error) {
+	return t.changeStatus(stub, key, statusBarred, reason)
+}
+
+//Reactivate a suspended or barred subscriber
+func (t *SimpleChaincode) reactivateSubscriber(stub shim.ChaincodeStubInterface, key string, reason string) ([]byte, error) {
+	return t.changeStatus(stub, key, statusActive, reason)
+}
+
+//Terminate a subscriber. The record stays on the ledger for audit.
+func (t *SimpleChaincode) terminateSubscriber(stub shim.ChaincodeStubInterface, key string, reason string) ([]byte, error) {
+	return t.changeStatus(stub, key, statusTerminated, reason)
+}
+
+// changeStatus moves a subscriber to a new lifecycle state. Only the
+// subscriber's home operator may do so.
+func (t *SimpleChaincode) changeStatus(stub shim.ChaincodeStubInterface, key string, to string, reason string) ([]byte, error) {
+
+	if reason == "" {
+		return nil, errors.New("a reason is required to change subscriber status")
+	}
+	rsDetailobj, err := t.getSubscriber(stub, key)
+	if err != nil {
+		return nil, err
+	}
+	caller, err := callerOperator(stub)
+	if err != nil {
+		return nil, err
+	}
+	if caller != rsDetailobj.HO {
+		return nil, fmt.Errorf("only home operator %s may change the status of %s", rsDetailobj.HO, key)
+	}
+
+	from := subscriberStatus(rsDetailobj)
+	allowed := false
+	for _, s := range lifecycleTransitions[from] {
+		if s == to {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, fmt.Errorf("subscriber %s cannot go from %s to %s", key, from, to)
+	}
+
+	currtime := txTime(stub)
+	rsDetailobj.Status = to
+	rsDetailobj.StatusReason = reason
+	rsDetailobj.StatusHistory = append(rsDetailobj.StatusHistory, statusChange{from, to, reason, caller, currtime})
+
+	bytes, _ := encodeSubscriber(rsDetailobj)
+	err = stub.PutState(rsDetailobj.PublicKey, bytes)
+	if err != nil {
+		fmt.Println("Error - could not update subscriber status")
+		return nil, err
+	}
+	fmt.Printf("Subscriber %s moved from %s to %s\n", key, from, to)
+	return newInvokeResponse(stub, statusFunctions[to], &rsDetailobj).marshal()
+}
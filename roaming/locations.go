@@ -0,0 +1,159 @@
+package roaming
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Every location a subscriber reports is kept as its own entry, so past
+// whereabouts survive the next discoverRP:
+//
+//	\x00location\x00<PublicKey>\x00<time>\x00<TxID>\x00 -> locationEntry
+//
+// The time is written in UTC with fixed width, so entries sort by time and
+// a time range is a key range.
+const locationTimeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// Sources of a location entry
+const (
+	locationEntered    = "enterData"
+	locationDiscovered = "discoverRP"
+)
+
+// locationEntry is where a subscriber was at Time and the roaming partner
+// serving it there, "" on the home network. Location, Lat and Long are
+// copied as stored on the subscriber, so they stay encrypted if its fields
+// are.
+type locationEntry struct {
+	schemaStamp
+	Key      string    `json:"key"`
+	Time     time.Time `json:"time"`
+	Location string    `json:"location"`
+	Lat      string    `json:"lat"`
+	Long     string    `json:"long"`
+	RP       string    `json:"rp"`
+	Source   string    `json:"source"`
+	TxID     string    `json:"txid"`
+}
+
+// locationTimeline is returned by queryLocations
+type locationTimeline struct {
+	Key     string          `json:"key"`
+	From    *time.Time      `json:"from,omitempty"`
+	To      *time.Time      `json:"to,omitempty"`
+	Entries []locationEntry `json:"entries"`
+}
+
+func locationKeyTime(when time.Time) string {
+	return when.UTC().Format(locationTimeLayout)
+}
+
+// recordLocation adds the current location of rs to its timeline
+func (t *SimpleChaincode) recordLocation(stub shim.ChaincodeStubInterface, rs rsDetailBlock, source string) error {
+	now := txTime(stub)
+	txid := stub.GetTxID()
+	entry := locationEntry{
+		Key:      rs.PublicKey,
+		Time:     now,
+		Location: rs.Location,
+		Lat:      rs.Lat,
+		Long:     rs.Long,
+		RP:       rs.RP,
+		Source:   source,
+		TxID:     txid,
+	}
+	return putRecord(stub, "location", []string{rs.PublicKey, locationKeyTime(now), txid}, &entry)
+}
+
+// parseTimeArg reads an optional RFC 3339 time argument
+func parseTimeArg(arg string) (*time.Time, error) {
+	if arg == "" {
+		return nil, nil
+	}
+	when, err := time.Parse(time.RFC3339, arg)
+	if err != nil {
+		return nil, fmt.Errorf("time %q is not RFC 3339, e.g. 2017-01-02T10:00:00Z", arg)
+	}
+	return &when, nil
+}
+
+//Query Locations: where a subscriber was between two times, and which
+//partner served it at each point, oldest first
+//args: key, optional from and to as RFC 3339 times, both inclusive
+func (t *SimpleChaincode) queryLocations(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) < 1 {
+		return nil, errors.New("Incorrect number of arguments. Expecting key and optional from and to times")
+	}
+	timeline := locationTimeline{Key: args[0], Entries: []locationEntry{}}
+	var err error
+	if len(args) > 1 {
+		if timeline.From, err = parseTimeArg(args[1]); err != nil {
+			return nil, err
+		}
+	}
+	if len(args) > 2 {
+		if timeline.To, err = parseTimeArg(args[2]); err != nil {
+			return nil, err
+		}
+	}
+	if timeline.From != nil && timeline.To != nil && timeline.To.Before(*timeline.From) {
+		return nil, errors.New("to is before from")
+	}
+
+	startKey, err := createCompositeKey("location", []string{timeline.Key})
+	if err != nil {
+		return nil, err
+	}
+	endKey := startKey + maxUnicodeRune
+	if timeline.From != nil {
+		if startKey, err = createCompositeKey("location", []string{timeline.Key, locationKeyTime(*timeline.From)}); err != nil {
+			return nil, err
+		}
+	}
+	if timeline.To != nil {
+		if endKey, err = createCompositeKey("location", []string{timeline.Key, locationKeyTime(*timeline.To)}); err != nil {
+			return nil, err
+		}
+		endKey += maxUnicodeRune
+	}
+	//Decrypt for callers holding the field keys
+	keys, err := readFieldKeys(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := stub.RangeQueryState(startKey, endKey)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+	for iter.HasNext() {
+		_, bytes, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		var entry locationEntry
+		if err = json.Unmarshal(bytes, &entry); err != nil {
+			return nil, err
+		}
+		if err = checkVersion(&entry); err != nil {
+			return nil, err
+		}
+		if keys != nil {
+			for _, value := range []*string{&entry.Location, &entry.Lat, &entry.Long} {
+				if !isSealed(*value) {
+					continue
+				}
+				if *value, err = keys.open(*value); err != nil {
+					return nil, err
+				}
+			}
+		}
+		timeline.Entries = append(timeline.Entries, entry)
+	}
+	return json.Marshal(timeline)
+}
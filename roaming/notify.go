@@ -0,0 +1,220 @@
+package roaming
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Notification types
+const (
+	notifyWelcome   = "WELCOME"
+	notifyBillShock = "BILL_SHOCK"
+	notifyDataLimit = "DATA_LIMIT"
+)
+
+// Caps used when the chaincode config does not set them. Bill shock alerts
+// go out at each of the percentages of the monetary cap (in the tariff's
+// currency) and of the data cap.
+const (
+	defaultMoneyCap  = 50.0
+	defaultDataCapMB = 200.0
+)
+
+var defaultAlertPercents = []int{80, 100}
+
+// alertConfig is the part of the chaincode config setting the caps roamers
+// are alerted against
+type alertConfig struct {
+	MoneyCap      float64 `json:"moneycap"`
+	DataCapMB     float64 `json:"datacapmb"`
+	AlertPercents []int   `json:"alertpercents"`
+}
+
+// notification is a message the home operator must pass on to a roamer.
+// It travels in the roaming event of the transaction that raised it.
+type notification struct {
+	ID        string       `json:"id"`
+	Type      string       `json:"type"`
+	Percent   int          `json:"percent,omitempty"`
+	Cap       float64      `json:"cap,omitempty"`
+	Used      float64      `json:"used,omitempty"`
+	Currency  string       `json:"currency,omitempty"`
+	Tariff    *tariffBlock `json:"tariff,omitempty"`
+	Message   string       `json:"message"`
+	Timestamp time.Time    `json:"timestamp"`
+}
+
+// roamingUsage accumulates what a subscriber spends while roaming on RP
+// since Since, under ("roamusage", key). Sent holds every notification of
+// the episode so none goes out twice.
+type roamingUsage struct {
+	schemaStamp
+	Key     string         `json:"key"`
+	RP      string         `json:"rp"`
+	Since   time.Time      `json:"since"`
+	Charges float64        `json:"charges"`
+	DataMB  float64        `json:"datamb"`
+	Sent    []notification `json:"sent"`
+}
+
+func (u roamingUsage) sent(id string) bool {
+	for _, n := range u.Sent {
+		if n.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *SimpleChaincode) getRoamingUsage(stub shim.ChaincodeStubInterface, key string) (roamingUsage, error) {
+	var u roamingUsage
+	err := getRecord(stub, "roamusage", []string{key}, &u)
+	return u, err
+}
+
+func (t *SimpleChaincode) putRoamingUsage(stub shim.ChaincodeStubInterface, u roamingUsage) error {
+	return putRecord(stub, "roamusage", []string{u.Key}, &u)
+}
+
+func (t *SimpleChaincode) alertCaps(stub shim.ChaincodeStubInterface) alertConfig {
+	caps := alertConfig{MoneyCap: defaultMoneyCap, DataCapMB: defaultDataCapMB, AlertPercents: defaultAlertPercents}
+	if config, err := t.getConfig(stub); err == nil {
+		if config.Alerts.MoneyCap > 0 {
+			caps.MoneyCap = config.Alerts.MoneyCap
+		}
+		if config.Alerts.DataCapMB > 0 {
+			caps.DataCapMB = config.Alerts.DataCapMB
+		}
+		if len(config.Alerts.AlertPercents) > 0 {
+			caps.AlertPercents = config.Alerts.AlertPercents
+		}
+	}
+	return caps
+}
+
+// welcome starts a new roaming episode when rs has just been authenticated
+// on a partner it was not already roaming on, returning the welcome
+// notification with the tariff that applies there
+func (t *SimpleChaincode) welcome(stub shim.ChaincodeStubInterface, rs rsDetailBlock, wasRoaming bool) ([]notification, error) {
+	if usage, err := t.getRoamingUsage(stub, rs.PublicKey); err == nil && wasRoaming && usage.RP == rs.RP {
+		return nil, nil
+	}
+	now := txTime(stub)
+	tariff := t.rates(stub, t.roamingRateType(stub, rs, now))
+	n := notification{
+		ID:       notifyWelcome,
+		Type:     notifyWelcome,
+		Currency: tariff.Currency,
+		Tariff:   &tariff,
+		Message: fmt.Sprintf("Welcome to %s. Calls cost %.2f/min, data %.2f/MB and SMS %.2f %s",
+			rs.RP, tariff.VoicePerMin, tariff.DataPerMB, tariff.SMS, tariff.Currency),
+		Timestamp: now,
+	}
+	usage := roamingUsage{Key: rs.PublicKey, RP: rs.RP, Since: now, Sent: []notification{n}}
+	if err := t.putRoamingUsage(stub, usage); err != nil {
+		return nil, err
+	}
+	return []notification{n}, nil
+}
+
+// recordCharge adds a charged session to the roaming episode it belongs to
+// and returns the alerts for every cap threshold it crosses
+func (t *SimpleChaincode) recordCharge(stub shim.ChaincodeStubInterface, s sessionBlock) ([]notification, error) {
+	usage, err := t.getRoamingUsage(stub, s.Key)
+	if err != nil || s.RP == "" || usage.RP != s.RP || s.Start.Before(usage.Since) {
+		//Not roaming, or the session predates this episode
+		return nil, nil
+	}
+	usage.Charges += s.Charges
+	if s.Type == sessionData {
+		usage.DataMB += s.Volume
+	}
+
+	caps := t.alertCaps(stub)
+	currency := t.rates(stub, s.RateType).Currency
+	now := txTime(stub)
+	var notes []notification
+	for _, percent := range caps.AlertPercents {
+		checks := []struct {
+			kind string
+			cap  float64
+			used float64
+			unit string
+		}{
+			{notifyBillShock, caps.MoneyCap, usage.Charges, currency},
+			{notifyDataLimit, caps.DataCapMB, usage.DataMB, "MB"},
+		}
+		for _, c := range checks {
+			id := fmt.Sprintf("%s-%d", c.kind, percent)
+			if c.cap <= 0 || c.used < c.cap*float64(percent)/100 || usage.sent(id) {
+				continue
+			}
+			n := notification{
+				ID:        id,
+				Type:      c.kind,
+				Percent:   percent,
+				Cap:       c.cap,
+				Used:      c.used,
+				Currency:  currency,
+				Message:   fmt.Sprintf("You have used %.2f of your %.2f %s roaming limit (%d%%)", c.used, c.cap, c.unit, percent),
+				Timestamp: now,
+			}
+			usage.Sent = append(usage.Sent, n)
+			notes = append(notes, n)
+		}
+	}
+	if err = t.putRoamingUsage(stub, usage); err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+//Set Alert Caps: change the caps and percentages roamers are alerted at
+//args: caps JSON e.g. {"moneycap":50,"datacapmb":200,"alertpercents":[80,100]}
+func (t *SimpleChaincode) setAlertCaps(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if err := callerIsAdmin(stub); err != nil {
+		return nil, err
+	}
+	if len(args) < 1 {
+		return nil, errors.New("Incorrect number of arguments. Expecting caps JSON")
+	}
+	var caps alertConfig
+	if err := json.Unmarshal([]byte(args[0]), &caps); err != nil {
+		return nil, fmt.Errorf("invalid alert caps: %s", err)
+	}
+	if caps.MoneyCap < 0 || caps.DataCapMB < 0 {
+		return nil, errors.New("caps cannot be negative")
+	}
+	for _, p := range caps.AlertPercents {
+		if p < 1 {
+			return nil, errors.New("alert percentages must be positive")
+		}
+	}
+	config, err := t.getConfig(stub)
+	if err != nil {
+		return nil, errors.New("chaincode is not initialised")
+	}
+	config.Alerts = caps
+	if err = t.putConfig(stub, config); err != nil {
+		return nil, err
+	}
+	resp := newInvokeResponse(stub, "setAlertCaps", nil)
+	resp.Result = config
+	return resp.marshal()
+}
+
+//Query the current roaming episode of a subscriber and the notifications sent
+func (t *SimpleChaincode) queryRoamingUsage(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) < 1 {
+		return nil, errors.New("Incorrect number of arguments. Expecting key")
+	}
+	usage, err := t.getRoamingUsage(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(usage)
+}
@@ -0,0 +1,212 @@
+package roaming
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Operators are registered under ("operator", Name). Two indexes find them
+// from the identifiers other systems use:
+//
+//	\x00operatormsp\x00<MSPID>\x00<Name>\x00        -> empty value
+//	\x00operatorplmn\x00<MCC>-<MNC>\x00<Name>\x00   -> empty value
+//
+// An MSP ID or MCC/MNC pair belongs to one operator only.
+const (
+	operatorMSPIndex  = "operatormsp"
+	operatorPLMNIndex = "operatorplmn"
+)
+
+var (
+	mccPattern      = regexp.MustCompile(`^[0-9]{3}$`)
+	mncPattern      = regexp.MustCompile(`^[0-9]{2,3}$`)
+	currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+	tadigPattern    = regexp.MustCompile(`^[A-Z]{3}[A-Z0-9]{2}$`)
+)
+
+// operatorBlock is a network taking part in roaming. MCC and MNC identify
+// it on the radio network, MSPID on the Fabric network and TADIG in
+// roaming data exchange. Currency is what it bills partners in.
+// DomesticTariff is what its own subscribers pay at home, and in
+// Roam-Like-At-Home zones.
+type operatorBlock struct {
+	schemaStamp
+	Name           string            `json:"name"`
+	MCC            string            `json:"mcc"`
+	MNC            string            `json:"mnc"`
+	Country        string            `json:"country"`
+	MSPID          string            `json:"mspid"`
+	Currency       string            `json:"currency"`
+	TADIG          string            `json:"tadig"`
+	Contacts       []operatorContact `json:"contacts"`
+	Coverage       []coverageArea    `json:"coverage"`
+	DomesticTariff string            `json:"domestictariff"`
+}
+
+// operatorContact is someone to reach at an operator, e.g. for "roaming",
+// "billing" or "fraud" matters
+type operatorContact struct {
+	Role  string `json:"role"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Phone string `json:"phone"`
+}
+
+// plmn is the operator's MCC/MNC pair as indexed, or "" if it has none
+func (op operatorBlock) plmn() string {
+	if op.MCC == "" {
+		return ""
+	}
+	return op.MCC + "-" + op.MNC
+}
+
+// validate checks the codes op has. Operators seeded before the registry
+// may lack them; onboardOperator requires them all.
+func (op operatorBlock) validate() error {
+	if op.Name == "" {
+		return errors.New("operator has no name")
+	}
+	if op.MCC != "" || op.MNC != "" {
+		if !mccPattern.MatchString(op.MCC) || !mncPattern.MatchString(op.MNC) {
+			return fmt.Errorf("operator %s: MCC must be 3 digits and MNC 2 or 3", op.Name)
+		}
+	}
+	if op.Currency != "" && !currencyPattern.MatchString(op.Currency) {
+		return fmt.Errorf("operator %s: currency %q is not an ISO 4217 code", op.Name, op.Currency)
+	}
+	if op.TADIG != "" && !tadigPattern.MatchString(op.TADIG) {
+		return fmt.Errorf("operator %s: TADIG code %q must be 5 characters", op.Name, op.TADIG)
+	}
+	return nil
+}
+
+// putOperator registers op, or replaces the registration of the same name,
+// keeping the MSP and MCC/MNC indexes in step
+func (t *SimpleChaincode) putOperator(stub shim.ChaincodeStubInterface, op operatorBlock) error {
+	if err := op.validate(); err != nil {
+		return err
+	}
+	if err := checkOperatorIndex(stub, operatorMSPIndex, op.MSPID, op.Name); err != nil {
+		return err
+	}
+	if err := checkOperatorIndex(stub, operatorPLMNIndex, op.plmn(), op.Name); err != nil {
+		return err
+	}
+	if old, err := t.getOperator(stub, op.Name); err == nil {
+		if err = delIndex(stub, operatorMSPIndex, old.MSPID, old.Name); err != nil {
+			return err
+		}
+		if err = delIndex(stub, operatorPLMNIndex, old.plmn(), old.Name); err != nil {
+			return err
+		}
+	}
+	if err := putIndex(stub, operatorMSPIndex, op.MSPID, op.Name); err != nil {
+		return err
+	}
+	if err := putIndex(stub, operatorPLMNIndex, op.plmn(), op.Name); err != nil {
+		return err
+	}
+	return putRecord(stub, "operator", []string{op.Name}, &op)
+}
+
+// checkOperatorIndex rejects value if another operator than name holds it
+func checkOperatorIndex(stub shim.ChaincodeStubInterface, index string, value string, name string) error {
+	if value == "" {
+		return nil
+	}
+	names, err := indexKeys(stub, index, []string{value})
+	if err != nil {
+		return err
+	}
+	for _, holder := range names {
+		if holder != name {
+			return fmt.Errorf("%s %s is already registered to %s", index, value, holder)
+		}
+	}
+	return nil
+}
+
+func (t *SimpleChaincode) getOperator(stub shim.ChaincodeStubInterface, name string) (operatorBlock, error) {
+	var op operatorBlock
+	err := getRecord(stub, "operator", []string{name}, &op)
+	return op, err
+}
+
+// operatorByIndex returns the operator holding value in index
+func (t *SimpleChaincode) operatorByIndex(stub shim.ChaincodeStubInterface, index string, value string) (operatorBlock, error) {
+	names, err := indexKeys(stub, index, []string{value})
+	if err != nil {
+		return operatorBlock{}, err
+	}
+	if len(names) == 0 {
+		return operatorBlock{}, fmt.Errorf("no operator registered with %s %s", index, value)
+	}
+	return t.getOperator(stub, names[0])
+}
+
+// checkOperator rejects names that are not registered operators. role says
+// what the name was given as, for the error.
+func (t *SimpleChaincode) checkOperator(stub shim.ChaincodeStubInterface, role string, name string) error {
+	if _, err := t.getOperator(stub, name); err != nil {
+		return fmt.Errorf("%s %s is not a registered operator", role, name)
+	}
+	return nil
+}
+
+//Onboard Operator: register a network, or update its registration
+//args: operator JSON e.g. {"name":"XYZ","mcc":"214","mnc":"01","country":"ES","mspid":"XYZMSP",
+//"currency":"EUR","tadig":"ESPXY","contacts":[{"role":"roaming","email":"roaming@xyz.example"}]}
+func (t *SimpleChaincode) onboardOperator(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if err := callerIsAdmin(stub); err != nil {
+		return nil, err
+	}
+	if len(args) < 1 {
+		return nil, errors.New("Incorrect number of arguments. Expecting operator JSON")
+	}
+	var op operatorBlock
+	if err := json.Unmarshal([]byte(args[0]), &op); err != nil {
+		return nil, fmt.Errorf("invalid operator: %s", err)
+	}
+	if op.Name == "" || op.MCC == "" || op.MNC == "" || op.Country == "" || op.MSPID == "" || op.Currency == "" || op.TADIG == "" {
+		return nil, errors.New("operator needs name, mcc, mnc, country, mspid, currency and tadig")
+	}
+	if len(op.Contacts) == 0 {
+		return nil, fmt.Errorf("operator %s needs at least one contact", op.Name)
+	}
+	if err := t.putOperator(stub, op); err != nil {
+		return nil, err
+	}
+	resp := newInvokeResponse(stub, "onboardOperator", nil)
+	resp.Result = op
+	return resp.marshal()
+}
+
+//Query Operator: look up a registered operator
+//args: name, or mcc and mnc; no args lists every operator
+func (t *SimpleChaincode) queryOperator(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) == 0 {
+		ops, err := t.listOperators(stub)
+		if err != nil {
+			return nil, err
+		}
+		if ops == nil {
+			ops = []operatorBlock{}
+		}
+		return json.Marshal(ops)
+	}
+	var op operatorBlock
+	var err error
+	if len(args) > 1 {
+		op, err = t.operatorByIndex(stub, operatorPLMNIndex, args[0]+"-"+args[1])
+	} else {
+		op, err = t.getOperator(stub, args[0])
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(op)
+}
@@ -0,0 +1,135 @@
+package roaming
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// partnerOption is one network a subscriber could attach to, priced at the
+// tariff it would be rated under there: the home operator's domestic tariff
+// inside a Roam-Like-At-Home zone, the agreement's tariff elsewhere
+type partnerOption struct {
+	Operator    string  `json:"operator"`
+	Country     string  `json:"country"`
+	Area        string  `json:"area"`
+	DistanceKm  float64 `json:"distancekm"`
+	Tariff      string  `json:"tariff"`
+	Zone        string  `json:"zone,omitempty"`
+	Currency    string  `json:"currency"`
+	VoicePerMin float64 `json:"voicepermin"`
+	DataPerMB   float64 `json:"datapermb"`
+	SMS         float64 `json:"sms"`
+}
+
+// listOperators returns every registered operator
+func (t *SimpleChaincode) listOperators(stub shim.ChaincodeStubInterface) ([]operatorBlock, error) {
+	var ops []operatorBlock
+	err := listRecords(stub, "operator", func() interface{} {
+		ops = append(ops, operatorBlock{})
+		return &ops[len(ops)-1]
+	})
+	return ops, err
+}
+
+//Recommend Partners: list the networks covering a location that the
+//subscriber's HO has a valid agreement with, cheapest first at the price
+//the subscriber would be charged on each
+//args: key, lat, long, optional ranking voice, data or sms (default voice);
+//ties are broken by the other prices in that order
+func (t *SimpleChaincode) recommendPartners(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) < 3 {
+		return nil, errors.New("Incorrect number of arguments. Expecting key, lat, long and optional ranking")
+	}
+	at, err := parsePoint(args[1], args[2])
+	if err != nil {
+		return nil, err
+	}
+	ranking := sessionVoice
+	if len(args) > 3 && args[3] != "" {
+		ranking = args[3]
+	}
+	//Options carry no voice in price, so only these can rank them
+	if ranking != sessionVoice && ranking != sessionData && ranking != sessionSMS {
+		return nil, fmt.Errorf("cannot rank by %q", ranking)
+	}
+	rs, err := t.getSubscriber(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+	ops, err := t.listOperators(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	now := txTime(stub)
+	options := []partnerOption{}
+	for _, op := range ops {
+		if op.Name == rs.HO {
+			continue
+		}
+		area, ok := op.covers(at.Lat, at.Long)
+		if !ok {
+			continue
+		}
+		if _, err := t.getAgreement(stub, rs.HO, op.Name, now); err != nil {
+			continue
+		}
+		//Rate the subscriber as if it had attached to op
+		visiting := rs
+		visiting.RP = op.Name
+		visiting.Roaming = "True"
+		tariff, err := t.getTariff(stub, t.roamingRateType(stub, visiting, now))
+		if err != nil {
+			continue
+		}
+		zone, _ := t.rlahZone(stub, rs.HO, op.Name)
+		options = append(options, partnerOption{
+			Operator:    op.Name,
+			Country:     op.Country,
+			Area:        area.Name,
+			DistanceKm:  distanceKm(area.Lat, area.Long, at.Lat, at.Long),
+			Tariff:      tariff.ID,
+			Zone:        zone.ID,
+			Currency:    tariff.Currency,
+			VoicePerMin: tariff.VoicePerMin,
+			DataPerMB:   tariff.DataPerMB,
+			SMS:         tariff.SMS,
+		})
+	}
+
+	order := []string{ranking}
+	for _, sessionType := range []string{sessionVoice, sessionData, sessionSMS} {
+		if sessionType != ranking {
+			order = append(order, sessionType)
+		}
+	}
+	sort.Sort(byPrice{options, order})
+	return json.Marshal(options)
+}
+
+// byPrice sorts options by their prices for the session types in order,
+// then by operator name
+type byPrice struct {
+	options []partnerOption
+	order   []string
+}
+
+func (b byPrice) Len() int      { return len(b.options) }
+func (b byPrice) Swap(i, j int) { b.options[i], b.options[j] = b.options[j], b.options[i] }
+func (b byPrice) Less(i, j int) bool {
+	for _, sessionType := range b.order {
+		pi, pj := b.options[i].price(sessionType), b.options[j].price(sessionType)
+		if pi != pj {
+			return pi < pj
+		}
+	}
+	return b.options[i].Operator < b.options[j].Operator
+}
+
+func (p partnerOption) price(sessionType string) float64 {
+	return unitPrice(tariffBlock{VoicePerMin: p.VoicePerMin, DataPerMB: p.DataPerMB, SMS: p.SMS}, sessionType)
+}
@@ -0,0 +1,218 @@
+package roaming
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+const defaultCycleDays = 30
+
+// allowances are the minutes and MB a plan includes per cycle, at home
+// (local, which includes Roam-Like-At-Home zones) and roaming
+type allowances struct {
+	VoiceOutL float64 `json:"voiceoutl"`
+	VoiceInL  float64 `json:"voiceinl"`
+	DataL     float64 `json:"datal"`
+	VoiceOutR float64 `json:"voiceoutr"`
+	VoiceInR  float64 `json:"voiceinr"`
+	DataR     float64 `json:"datar"`
+}
+
+// bucket returns the allowance session s draws on, or nil if its type has
+// none
+func (a *allowances) bucket(s sessionBlock) *float64 {
+	local := !s.Roaming || s.Zone != ""
+	switch {
+	case s.Type == sessionVoice && local:
+		return &a.VoiceOutL
+	case s.Type == sessionVoice:
+		return &a.VoiceOutR
+	case s.Type == sessionVoiceIn && local:
+		return &a.VoiceInL
+	case s.Type == sessionVoiceIn:
+		return &a.VoiceInR
+	case s.Type == sessionData && local:
+		return &a.DataL
+	case s.Type == sessionData:
+		return &a.DataR
+	}
+	return nil
+}
+
+// planBlock is a home operator's subscription plan, stored under
+// ("plan", ID). Usage within Allowances is not charged; counters reset
+// every CycleDays from the day a subscriber joined the plan.
+type planBlock struct {
+	schemaStamp
+	ID         string     `json:"id"`
+	HO         string     `json:"ho"`
+	Name       string     `json:"name"`
+	CycleDays  int        `json:"cycledays"`
+	Allowances allowances `json:"allowances"`
+}
+
+// allowanceCounter is what is left of a subscriber's allowances in the
+// current cycle, under ("allowance", key)
+type allowanceCounter struct {
+	schemaStamp
+	Key        string     `json:"key"`
+	Plan       string     `json:"plan"`
+	CycleStart time.Time  `json:"cyclestart"`
+	CycleEnd   time.Time  `json:"cycleend"`
+	Remaining  allowances `json:"remaining"`
+}
+
+func (t *SimpleChaincode) putPlan(stub shim.ChaincodeStubInterface, p planBlock) error {
+	if p.ID == "" || p.HO == "" {
+		return errors.New("plan needs id and ho")
+	}
+	if p.CycleDays == 0 {
+		p.CycleDays = defaultCycleDays
+	}
+	if p.CycleDays < 0 {
+		return errors.New("cycledays cannot be negative")
+	}
+	return putRecord(stub, "plan", []string{p.ID}, &p)
+}
+
+func (t *SimpleChaincode) getPlan(stub shim.ChaincodeStubInterface, id string) (planBlock, error) {
+	var p planBlock
+	err := getRecord(stub, "plan", []string{id}, &p)
+	return p, err
+}
+
+// rollCycle moves c on to the cycle containing when, refilling its
+// allowances, if the cycle it holds has ended
+func (c *allowanceCounter) rollCycle(p planBlock, when time.Time) {
+	if when.Before(c.CycleEnd) {
+		return
+	}
+	cycle := time.Duration(p.CycleDays) * 24 * time.Hour
+	elapsed := when.Sub(c.CycleStart) / cycle
+	c.CycleStart = c.CycleStart.Add(elapsed * cycle)
+	c.CycleEnd = c.CycleStart.Add(cycle)
+	c.Remaining = p.Allowances
+}
+
+// useAllowance takes quantity of s out of the subscriber's allowance and
+// returns the part left to charge. Counters are only updated when record
+// is set, so estimates can share it.
+func (t *SimpleChaincode) useAllowance(stub shim.ChaincodeStubInterface, s sessionBlock, quantity float64, record bool) (float64, error) {
+	var c allowanceCounter
+	if err := getRecord(stub, "allowance", []string{s.Key}, &c); err != nil {
+		//No plan
+		return quantity, nil
+	}
+	p, err := t.getPlan(stub, c.Plan)
+	if err != nil {
+		return quantity, nil
+	}
+	c.rollCycle(p, s.Start)
+	bucket := c.Remaining.bucket(s)
+	if bucket == nil {
+		return quantity, nil
+	}
+	covered := math.Min(*bucket, quantity)
+	*bucket -= covered
+	if record {
+		if err = putRecord(stub, "allowance", []string{s.Key}, &c); err != nil {
+			return 0, err
+		}
+	}
+	return quantity - covered, nil
+}
+
+//Set Plan: a home operator defines a subscription plan
+//args: plan JSON e.g. {"id":"ABC-10","allowances":{"voiceoutl":100,"datal":1000,"voiceoutr":10}}
+func (t *SimpleChaincode) setPlan(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) < 1 {
+		return nil, errors.New("Incorrect number of arguments. Expecting plan JSON")
+	}
+	var p planBlock
+	if err := json.Unmarshal([]byte(args[0]), &p); err != nil {
+		return nil, fmt.Errorf("invalid plan: %s", err)
+	}
+	caller, err := callerOperator(stub)
+	if err != nil {
+		return nil, err
+	}
+	if p.HO == "" {
+		p.HO = caller
+	}
+	if p.HO != caller {
+		return nil, fmt.Errorf("only %s may set its plans", p.HO)
+	}
+	if existing, err := t.getPlan(stub, p.ID); err == nil && existing.HO != caller {
+		return nil, fmt.Errorf("plan %s belongs to %s", p.ID, existing.HO)
+	}
+	if err = t.putPlan(stub, p); err != nil {
+		return nil, err
+	}
+	resp := newInvokeResponse(stub, "setPlan", nil)
+	resp.Result = p
+	return resp.marshal()
+}
+
+//Assign Plan: the home operator moves a subscriber onto a plan, starting a
+//new cycle with full allowances
+func (t *SimpleChaincode) assignPlan(stub shim.ChaincodeStubInterface, key string, planID string) ([]byte, error) {
+	rsDetailobj, err := t.getSubscriber(stub, key)
+	if err != nil {
+		return nil, err
+	}
+	caller, err := callerOperator(stub)
+	if err != nil {
+		return nil, err
+	}
+	if caller != rsDetailobj.HO {
+		return nil, fmt.Errorf("only home operator %s may change the plan of %s", rsDetailobj.HO, key)
+	}
+	p, err := t.getPlan(stub, planID)
+	if err != nil {
+		return nil, err
+	}
+	if p.HO != rsDetailobj.HO {
+		return nil, fmt.Errorf("plan %s is not offered by %s", planID, rsDetailobj.HO)
+	}
+
+	now := txTime(stub)
+	c := allowanceCounter{
+		Key:        key,
+		Plan:       p.ID,
+		CycleStart: now,
+		CycleEnd:   now.Add(time.Duration(p.CycleDays) * 24 * time.Hour),
+		Remaining:  p.Allowances,
+	}
+	if err = putRecord(stub, "allowance", []string{key}, &c); err != nil {
+		return nil, err
+	}
+	rsDetailobj.Plan = p.ID
+	bytes, _ := encodeSubscriber(rsDetailobj)
+	if err = stub.PutState(key, bytes); err != nil {
+		return nil, err
+	}
+	resp := newInvokeResponse(stub, "assignPlan", &rsDetailobj)
+	resp.Result = c
+	return resp.marshal()
+}
+
+//Query what is left of a subscriber's plan allowances this cycle
+func (t *SimpleChaincode) queryAllowance(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) < 1 {
+		return nil, errors.New("Incorrect number of arguments. Expecting key")
+	}
+	var c allowanceCounter
+	if err := getRecord(stub, "allowance", []string{args[0]}, &c); err != nil {
+		return nil, err
+	}
+	//Report a cycle that has ended as refilled
+	if p, err := t.getPlan(stub, c.Plan); err == nil {
+		c.rollCycle(p, txTime(stub))
+	}
+	return json.Marshal(c)
+}
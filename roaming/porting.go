@@ -0,0 +1,269 @@
+package roaming
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Porting request states
+const (
+	portRequested = "REQUESTED"
+	portApproved  = "APPROVED"
+	portRejected  = "REJECTED"
+	portCompleted = "COMPLETED"
+)
+
+// portRequest is one attempt to move an MSISDN from a donor to a recipient
+// home operator. Usage up to PortTime is billed by the donor; DonorCharges
+// holds what the sessions still open at PortTime were charged when closed.
+type portRequest struct {
+	Donor        string    `json:"donor"`
+	Recipient    string    `json:"recipient"`
+	Status       string    `json:"status"`
+	Reason       string    `json:"reason"`
+	Requested    time.Time `json:"requested"`
+	Approved     time.Time `json:"approved"`
+	PortTime     time.Time `json:"porttime"`
+	DonorCharges float64   `json:"donorcharges"`
+}
+
+// portingRecord is kept per MSISDN so roaming partners can find the current
+// home operator of a ported number
+type portingRecord struct {
+	schemaStamp
+	MSISDN    string        `json:"msisdn"`
+	PublicKey string        `json:"publickey"`
+	HO        string        `json:"ho"`
+	Pending   *portRequest  `json:"pending"`
+	Ports     []portRequest `json:"ports"`
+}
+
+func portingKey(msisdn string) (string, error) {
+	return createCompositeKey("port", []string{msisdn})
+}
+
+func (t *SimpleChaincode) getPortingRecord(stub shim.ChaincodeStubInterface, msisdn string) (portingRecord, error) {
+	var rec portingRecord
+	key, err := portingKey(msisdn)
+	if err != nil {
+		return rec, err
+	}
+	bytes, err := stub.GetState(key)
+	if err != nil {
+		return rec, err
+	}
+	if bytes == nil {
+		return rec, fmt.Errorf("no porting record for %s", msisdn)
+	}
+	if err = json.Unmarshal(bytes, &rec); err != nil {
+		return rec, err
+	}
+	return rec, checkVersion(&rec)
+}
+
+func (t *SimpleChaincode) putPortingRecord(stub shim.ChaincodeStubInterface, rec portingRecord) error {
+	key, err := portingKey(rec.MSISDN)
+	if err != nil {
+		return err
+	}
+	rec.stamp()
+	bytes, _ := json.Marshal(rec)
+	return stub.PutState(key, bytes)
+}
+
+//Port In: the recipient operator asks to take over a subscriber's MSISDN
+func (t *SimpleChaincode) requestPort(stub shim.ChaincodeStubInterface, key string, msisdn string) ([]byte, error) {
+
+	rsDetailobj, err := t.getSubscriber(stub, key)
+	if err != nil {
+		return nil, err
+	}
+	held := rsDetailobj.MSISDN
+	if isSealed(held) {
+		keys, err := readFieldKeys(stub)
+		if err != nil {
+			return nil, err
+		}
+		if keys == nil {
+			return nil, fmt.Errorf("subscriber %s has encrypted fields, field keys are required", key)
+		}
+		if held, err = keys.open(held); err != nil {
+			return nil, err
+		}
+	}
+	if held != msisdn {
+		return nil, fmt.Errorf("subscriber %s does not hold MSISDN %s", key, msisdn)
+	}
+	if err = checkActive(rsDetailobj); err != nil {
+		return nil, err
+	}
+	recipient, err := callerOperator(stub)
+	if err != nil {
+		return nil, err
+	}
+	if recipient == rsDetailobj.HO {
+		return nil, fmt.Errorf("%s is already the home operator of %s", recipient, msisdn)
+	}
+
+	rec, err := t.getPortingRecord(stub, msisdn)
+	if err != nil {
+		rec = portingRecord{MSISDN: msisdn}
+	}
+	if rec.Pending != nil {
+		return nil, fmt.Errorf("a port of %s to %s is already %s", msisdn, rec.Pending.Recipient, rec.Pending.Status)
+	}
+	rec.PublicKey = key
+	rec.HO = rsDetailobj.HO
+
+	currtime := txTime(stub)
+	rec.Pending = &portRequest{Donor: rsDetailobj.HO, Recipient: recipient, Status: portRequested, Requested: currtime}
+	if err = t.putPortingRecord(stub, rec); err != nil {
+		fmt.Println("Error - could not store port request")
+		return nil, err
+	}
+	fmt.Printf("Port of %s from %s to %s requested\n", msisdn, rsDetailobj.HO, recipient)
+	resp := newInvokeResponse(stub, "requestPort", &rsDetailobj)
+	resp.Result = rec
+	return resp.marshal()
+}
+
+//Port Out: the donor operator approves or rejects a pending port
+func (t *SimpleChaincode) answerPort(stub shim.ChaincodeStubInterface, msisdn string, approve bool, reason string) ([]byte, error) {
+
+	rec, err := t.getPortingRecord(stub, msisdn)
+	if err != nil {
+		return nil, err
+	}
+	if rec.Pending == nil || rec.Pending.Status != portRequested {
+		return nil, fmt.Errorf("no port request awaiting approval for %s", msisdn)
+	}
+	donor, err := callerOperator(stub)
+	if err != nil {
+		return nil, err
+	}
+	if donor != rec.Pending.Donor {
+		return nil, fmt.Errorf("only donor operator %s may answer the port of %s", rec.Pending.Donor, msisdn)
+	}
+
+	currtime := txTime(stub)
+	if approve {
+		rec.Pending.Status = portApproved
+		rec.Pending.Approved = currtime
+	} else {
+		if reason == "" {
+			return nil, errors.New("a reason is required to reject a port")
+		}
+		rec.Pending.Status = portRejected
+		rec.Pending.Reason = reason
+		rec.Ports = append(rec.Ports, *rec.Pending)
+		rec.Pending = nil
+	}
+	if err = t.putPortingRecord(stub, rec); err != nil {
+		fmt.Println("Error - could not store port answer")
+		return nil, err
+	}
+	fmt.Printf("Port of %s answered, approved: %v\n", msisdn, approve)
+	function := "approvePort"
+	if !approve {
+		function = "rejectPort"
+	}
+	resp := newInvokeResponse(stub, function, nil)
+	resp.Key = rec.PublicKey
+	resp.Result = rec
+	return resp.marshal()
+}
+
+//Complete Port: the recipient re-homes an approved MSISDN. Open sessions are
+//closed and charged to the donor at the port timestamp, and the donor's
+//plan and SIM are released.
+func (t *SimpleChaincode) completePort(stub shim.ChaincodeStubInterface, msisdn string) ([]byte, error) {
+
+	rec, err := t.getPortingRecord(stub, msisdn)
+	if err != nil {
+		return nil, err
+	}
+	if rec.Pending == nil || rec.Pending.Status != portApproved {
+		return nil, fmt.Errorf("no approved port for %s", msisdn)
+	}
+	recipient, err := callerOperator(stub)
+	if err != nil {
+		return nil, err
+	}
+	if recipient != rec.Pending.Recipient {
+		return nil, fmt.Errorf("only recipient operator %s may complete the port of %s", rec.Pending.Recipient, msisdn)
+	}
+	rsDetailobj, err := t.getSubscriber(stub, rec.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	currtime := txTime(stub)
+
+	//Billing cut over: close open sessions and settle them with the donor
+	rec.Pending.DonorCharges, err = t.settleSessions(stub, rsDetailobj, currtime)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println("Closed open sessions at port time, charges to donor: ", rec.Pending.DonorCharges)
+
+	//The donor's plan and SIM stay with the donor. The subscriber cannot
+	//roam until the recipient gives it a SIM with assignSIM, and has no
+	//allowances until it is given a plan with assignPlan.
+	allowanceKey, err := createCompositeKey("allowance", []string{rsDetailobj.PublicKey})
+	if err != nil {
+		return nil, err
+	}
+	if err = stub.DelState(allowanceKey); err != nil {
+		return nil, err
+	}
+	rsDetailobj.Plan = ""
+	rsDetailobj.IMSI = ""
+	rsDetailobj.ICCID = ""
+	rsDetailobj.SIMState = simInactive
+
+	rsDetailobj.HO = recipient
+	if rsDetailobj.RP == recipient {
+		rsDetailobj.RP = ""
+	}
+	if rsDetailobj.RP == "" {
+		rsDetailobj.Roaming = "FALSE"
+	}
+	rsDetailobj.RateType = ""
+	rsDetailobj.Action = "Port"
+	rsDetailobj.TransType = "Setup"
+	rsDetailobj.State = stateRegistered
+	rsDetailobj.Time = currtime
+	if _, err = t.putMSIDN(stub, rsDetailobj, rsDetailobj.PublicKey); err != nil {
+		return nil, err
+	}
+
+	rec.Pending.Status = portCompleted
+	rec.Pending.PortTime = currtime
+	rec.Ports = append(rec.Ports, *rec.Pending)
+	rec.Pending = nil
+	rec.HO = recipient
+	if err = t.putPortingRecord(stub, rec); err != nil {
+		fmt.Println("Error - could not store completed port")
+		return nil, err
+	}
+	fmt.Printf("MSISDN %s ported to %s\n", msisdn, recipient)
+	resp := newInvokeResponse(stub, "completePort", &rsDetailobj)
+	resp.Result = rec
+	return resp.marshal()
+}
+
+//Query the porting record of an MSISDN, e.g. to route to its current HO
+func (t *SimpleChaincode) queryPorting(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) < 1 {
+		return nil, errors.New("Incorrect number of arguments. Expecting MSISDN")
+	}
+	rec, err := t.getPortingRecord(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(rec)
+}
@@ -0,0 +1,112 @@
+package roaming
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 200
+)
+
+// subscriberPage is the result of listSubscribers. Pass Bookmark back to get
+// the next page; it is empty once the last page has been returned.
+type subscriberPage struct {
+	Records  []rsDetailBlock `json:"records"`
+	Count    int             `json:"count"`
+	Bookmark string          `json:"bookmark"`
+}
+
+// selectorFields maps the selector names accepted by listSubscribers to the
+// subscriber fields they match. Matching is exact but case-insensitive, as
+// Roaming has historically been written both as "True" and "TRUE".
+var selectorFields = map[string]func(rs rsDetailBlock) string{
+	"ho":       func(rs rsDetailBlock) string { return rs.HO },
+	"rp":       func(rs rsDetailBlock) string { return rs.RP },
+	"roaming":  func(rs rsDetailBlock) string { return rs.Roaming },
+	"flag":     func(rs rsDetailBlock) string { return rs.Flag },
+	"ratetype": func(rs rsDetailBlock) string { return rs.RateType },
+	"location": func(rs rsDetailBlock) string { return rs.Location },
+	"status":   func(rs rsDetailBlock) string { return subscriberStatus(rs) },
+	"state":    func(rs rsDetailBlock) string { return sessionState(rs) },
+	"plan":     func(rs rsDetailBlock) string { return rs.Plan },
+	"simstate": func(rs rsDetailBlock) string { return rs.SIMState },
+}
+
+func matchesSelector(rs rsDetailBlock, selector map[string]string) bool {
+	for field, want := range selector {
+		if !strings.EqualFold(selectorFields[field](rs), want) {
+			return false
+		}
+	}
+	return true
+}
+
+//List subscribers matching a selector, one page at a time
+//args: selector JSON e.g. {"ho":"ABC","roaming":"true"}, page size, bookmark
+func (t *SimpleChaincode) listSubscribers(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+
+	selector := map[string]string{}
+	if len(args) > 0 && args[0] != "" {
+		if err := json.Unmarshal([]byte(args[0]), &selector); err != nil {
+			return nil, fmt.Errorf("invalid selector: %s", err)
+		}
+	}
+	for field := range selector {
+		if _, ok := selectorFields[field]; !ok {
+			return nil, fmt.Errorf("cannot select on %q", field)
+		}
+	}
+	pageSize := defaultPageSize
+	if len(args) > 1 && args[1] != "" {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			return nil, errors.New("page size must be a positive number")
+		}
+		pageSize = n
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+	//Subscriber keys sort after the composite key namespace
+	start := "\x01"
+	if len(args) > 2 && args[2] != "" {
+		start = args[2] + "\x00"
+	}
+
+	iter, err := stub.RangeQueryState(start, maxUnicodeRune)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	page := subscriberPage{Records: []rsDetailBlock{}}
+	for iter.HasNext() {
+		key, bytes, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		rs, err := decodeSubscriber(bytes)
+		if err != nil || rs.PublicKey != key {
+			continue
+		}
+		if !matchesSelector(rs, selector) {
+			continue
+		}
+		page.Records = append(page.Records, rs)
+		if len(page.Records) == pageSize {
+			if iter.HasNext() {
+				page.Bookmark = key
+			}
+			break
+		}
+	}
+	page.Count = len(page.Records)
+	return json.Marshal(page)
+}
@@ -0,0 +1,107 @@
+package roaming
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// responseSchemaVersion is bumped whenever invokeResponse changes in a way
+// clients need to know about
+const responseSchemaVersion = 1
+
+// Invoke result codes. A transaction that fails returns an error instead
+// and is not committed; these describe transactions that were, including
+// outcomes a client must act on, such as a rejected authentication.
+const (
+	codeOK           = "OK"
+	codeAuthRejected = "AUTH_REJECTED"
+	codeFraud        = "FRAUD"
+	codePartial      = "PARTIAL"
+	codeSteered      = "STEERED"
+	codeNotFound     = "NOT_FOUND"
+)
+
+// invokeResponse is returned by every Invoke function, so clients learn the
+// outcome of a transaction without querying for it afterwards:
+//
+//	version    responseSchemaVersion
+//	function   the Invoke function that ran
+//	code       OK, AUTH_REJECTED, FRAUD, PARTIAL (bulk imports with
+//	           rejected rows), STEERED (attach turned away by the home
+//	           operator's steering policy, try a preferred partner) or
+//	           NOT_FOUND (no subscriber has the key; nothing was written)
+//	message    human readable detail for codes other than OK
+//	txid       the transaction ID
+//	key        subscriber PublicKey, if the function acts on one
+//	status     subscriber lifecycle status (ACTIVE, SUSPENDED, ...)
+//	state      subscriber roaming state (Registered ... Charged)
+//	roaming    roaming decision, "True" when attached to a partner
+//	rp         roaming partner
+//	ratetype   tariff the subscriber is rated with
+//	sessionid  the session started, ended or charged
+//	session    that session in full
+//	duration   minutes of the session, or of the subscriber's last call
+//	charges    charge of the session, or of the subscriber's last call
+//	flag       "Fraud" or "OVERAGE" when raised
+//	result     function specific detail: the porting record, bulk import
+//	           report, migration report, chaincode config, authentication
+//	           challenge or, when steered, the preferred partners
+//	notifications  welcome and limit alerts raised, to pass on to the
+//	           roamer; also carried by the transaction's event
+type invokeResponse struct {
+	Version   int           `json:"version"`
+	Function  string        `json:"function"`
+	Code      string        `json:"code"`
+	Message   string        `json:"message,omitempty"`
+	TxID      string        `json:"txid"`
+	Key       string        `json:"key,omitempty"`
+	Status    string        `json:"status,omitempty"`
+	State     string        `json:"state,omitempty"`
+	Roaming   string        `json:"roaming,omitempty"`
+	RP        string        `json:"rp,omitempty"`
+	RateType  string        `json:"ratetype,omitempty"`
+	SessionID string        `json:"sessionid,omitempty"`
+	Session   *sessionBlock `json:"session,omitempty"`
+	Duration  float64       `json:"duration"`
+	Charges   float64       `json:"charges"`
+	Flag      string        `json:"flag,omitempty"`
+	Result    interface{}   `json:"result,omitempty"`
+
+	Notifications []notification `json:"notifications,omitempty"`
+}
+
+// newInvokeResponse starts a response for function, describing rs if it is
+// not nil
+func newInvokeResponse(stub shim.ChaincodeStubInterface, function string, rs *rsDetailBlock) *invokeResponse {
+	resp := &invokeResponse{
+		Version:  responseSchemaVersion,
+		Function: function,
+		Code:     codeOK,
+		TxID:     stub.GetTxID(),
+	}
+	if rs != nil {
+		resp.Key = rs.PublicKey
+		resp.Status = subscriberStatus(*rs)
+		resp.State = sessionState(*rs)
+		resp.Roaming = rs.Roaming
+		resp.RP = rs.RP
+		resp.RateType = rs.RateType
+		resp.Duration = rs.Duration
+		resp.Charges = rs.Charges
+		resp.Flag = rs.Flag
+	}
+	return resp
+}
+
+// setSession reports on session s rather than the subscriber's last call
+func (r *invokeResponse) setSession(s sessionBlock) {
+	r.SessionID = s.ID
+	r.Session = &s
+	r.Duration = s.Duration
+	r.Charges = s.Charges
+}
+
+func (r *invokeResponse) marshal() ([]byte, error) {
+	return json.Marshal(r)
+}
@@ -0,0 +1,229 @@
+package roaming
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// schemaVersion is the layout every object is written with. Version 1 is
+// the original subscriber record, stored without a version, status, state
+// or call sessions; version 2 subscribers lack the numeric position and
+// its geo index entry. Readers upgrade older records in memory and
+// migrateSchema rewrites them on the ledger. Bump schemaVersion, and teach upgradeSubscriber the
+// step, whenever a stored layout changes incompatibly.
+const schemaVersion = 3
+
+// legacySessionID is the session the migration opens for a call that was
+// in progress, or ended but unpaid, under the version 1 layout
+const legacySessionID = "legacy"
+
+// schemaStamp is embedded in every stored object
+type schemaStamp struct {
+	SchemaVersion int `json:"schemaversion"`
+}
+
+func (s *schemaStamp) stamp() {
+	s.SchemaVersion = schemaVersion
+}
+
+// version returns the layout the object was written with
+func (s schemaStamp) version() int {
+	if s.SchemaVersion == 0 {
+		return 1
+	}
+	return s.SchemaVersion
+}
+
+// checkVersion refuses objects written by a newer chaincode, whose fields
+// this one would silently drop on the next write
+func checkVersion(v interface{}) error {
+	if obj, ok := v.(interface{ version() int }); ok && obj.version() > schemaVersion {
+		return fmt.Errorf("stored object has schema version %d, this chaincode understands up to %d", obj.version(), schemaVersion)
+	}
+	return nil
+}
+
+// encodeSubscriber marshals rs in the current layout
+func encodeSubscriber(rs rsDetailBlock) ([]byte, error) {
+	rs.stamp()
+	return json.Marshal(rs)
+}
+
+// decodeSubscriber unmarshals a subscriber record of any known version and
+// upgrades it to the current layout
+func decodeSubscriber(bytes []byte) (rsDetailBlock, error) {
+	var rs rsDetailBlock
+	if err := json.Unmarshal(bytes, &rs); err != nil {
+		return rs, err
+	}
+	if err := checkVersion(&rs); err != nil {
+		return rs, err
+	}
+	upgradeSubscriber(&rs)
+	return rs, nil
+}
+
+// upgradeSubscriber fills in the fields an older record lacks. The
+// version itself is left alone so migrateSchema can tell what it read.
+func upgradeSubscriber(rs *rsDetailBlock) {
+	if rs.version() < 2 {
+		if rs.Status == "" {
+			rs.Status = statusActive
+		}
+		rs.State = sessionState(*rs)
+	}
+	if rs.version() < 3 {
+		//Coordinates stored before they were validated are left unplaced
+		if locate(rs) != nil {
+			rs.Position = nil
+		}
+	}
+}
+
+// legacySession rebuilds the call session a version 1 record was in the
+// middle of, if any
+func legacySession(rs rsDetailBlock) (sessionBlock, bool) {
+	s := sessionBlock{
+		ID:          legacySessionID,
+		Key:         rs.PublicKey,
+		Type:        sessionVoice,
+		Destination: rs.Destination,
+		RP:          rs.RP,
+		RateType:    rs.RateType,
+		State:       rs.State,
+		Start:       rs.Time,
+	}
+	switch rs.State {
+	case stateInCall:
+		return s, true
+	case stateCallEnded:
+		//Version 1 stamped Time at call end and kept only the duration
+		s.End = rs.Time
+		s.Duration = rs.Duration
+		s.Start = rs.Time.Add(-time.Duration(rs.Duration * float64(time.Minute)))
+		return s, true
+	}
+	return s, false
+}
+
+// migrationReport is the result of one migrateSchema page. Pass Bookmark
+// back to continue; Done is set once every subscriber has been visited.
+type migrationReport struct {
+	Version  int    `json:"version"`
+	Scanned  int    `json:"scanned"`
+	Migrated int    `json:"migrated"`
+	Bookmark string `json:"bookmark"`
+	Done     bool   `json:"done"`
+}
+
+//Migrate Schema: rewrite up to page size subscriber records in the current
+//layout, opening sessions for legacy calls and indexing their MSISDN and
+//position
+//args: page size, bookmark
+func (t *SimpleChaincode) migrateSchema(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if err := callerIsAdmin(stub); err != nil {
+		return nil, err
+	}
+	pageSize := defaultPageSize
+	if len(args) > 0 && args[0] != "" {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			return nil, errors.New("page size must be a positive number")
+		}
+		pageSize = n
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+	start := "\x01"
+	if len(args) > 1 && args[1] != "" {
+		start = args[1] + "\x00"
+	}
+
+	iter, err := stub.RangeQueryState(start, maxUnicodeRune)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	report := migrationReport{Version: schemaVersion}
+	for iter.HasNext() {
+		key, bytes, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		var raw rsDetailBlock
+		if err = json.Unmarshal(bytes, &raw); err != nil || raw.PublicKey != key {
+			continue
+		}
+		report.Scanned++
+		if raw.version() < schemaVersion {
+			if err = t.migrateSubscriber(stub, raw); err != nil {
+				return nil, fmt.Errorf("migrating %s: %s", key, err)
+			}
+			report.Migrated++
+		}
+		if report.Scanned == pageSize {
+			if iter.HasNext() {
+				report.Bookmark = key
+			}
+			break
+		}
+	}
+	report.Done = report.Bookmark == ""
+	if report.Done {
+		config, err := t.getConfig(stub)
+		if err != nil {
+			//Ledgers set up before Init took a seed have no config yet
+			config = chaincodeConfig{Environment: envProduction, SeededAt: txTime(stub)}
+		}
+		config.SchemaVersion = schemaVersion
+		if err = t.putConfig(stub, config); err != nil {
+			return nil, err
+		}
+	}
+	fmt.Printf("Schema migration: scanned %d, migrated %d, done %v\n", report.Scanned, report.Migrated, report.Done)
+	resp := newInvokeResponse(stub, "migrateSchema", nil)
+	resp.Result = report
+	return resp.marshal()
+}
+
+// migrateSubscriber rewrites one record read in an older layout. Only
+// version 1 records kept their call on the subscriber; later ones already
+// have real sessions, and a session already stored is never replaced.
+func (t *SimpleChaincode) migrateSubscriber(stub shim.ChaincodeStubInterface, rs rsDetailBlock) error {
+	from := rs.version()
+	upgradeSubscriber(&rs)
+	if s, ok := legacySession(rs); ok && from < 2 {
+		sessionKey, err := createCompositeKey("session", []string{s.Key, s.ID})
+		if err != nil {
+			return err
+		}
+		existing, err := stub.GetState(sessionKey)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			if err = t.putSession(stub, s); err != nil {
+				return err
+			}
+		}
+	}
+	//Records written before the msisdn and geo indexes existed are not in them
+	if err := putIndex(stub, msisdnIndex, rs.MSISDN, rs.PublicKey); err != nil {
+		return err
+	}
+	if err := putIndex(stub, geoIndex, rs.geohash(), rs.PublicKey); err != nil {
+		return err
+	}
+	bytes, err := encodeSubscriber(rs)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(rs.PublicKey, bytes)
+}
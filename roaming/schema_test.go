@@ -0,0 +1,79 @@
+package roaming
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeVersion1Subscriber(t *testing.T) {
+	rs, err := decodeSubscriber([]byte(`{"publickey":"old1","msisdn":"14691234570","ho":"ABC","action":"Call End",` +
+		`"duration":3,"time":"2016-12-31T23:00:00Z","lat":"41.3851","long":"2.1734"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rs.version() != 1 {
+		t.Errorf("version = %d, want 1 until migrated", rs.version())
+	}
+	if rs.Status != statusActive || rs.State != stateCallEnded {
+		t.Errorf("status %q, state %q, want %s and %s", rs.Status, rs.State, statusActive, stateCallEnded)
+	}
+	if rs.Position == nil || rs.Position.Geohash != "sp3e3myte" {
+		t.Errorf("position = %+v, want geohash sp3e3myte", rs.Position)
+	}
+}
+
+func TestDecodeVersion2Subscriber(t *testing.T) {
+	// coordinates written before they were validated are left unplaced
+	rs, err := decodeSubscriber([]byte(`{"schemaversion":2,"publickey":"old2","status":"SUSPENDED","state":"InCall","lat":"95","long":"2"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rs.Status != statusSuspended || rs.State != stateInCall || rs.Position != nil {
+		t.Errorf("decoded %+v", rs)
+	}
+}
+
+func TestDecodeNewerSubscriber(t *testing.T) {
+	_, err := decodeSubscriber([]byte(`{"schemaversion":99,"publickey":"new1"}`))
+	if err == nil || !strings.Contains(err.Error(), "schema version 99") {
+		t.Errorf("decoding a newer record returned %v", err)
+	}
+}
+
+func TestEncodeSubscriberStamps(t *testing.T) {
+	bytes, err := encodeSubscriber(rsDetailBlock{PublicKey: "rs1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, err := decodeSubscriber(bytes)
+	if err != nil || rs.version() != schemaVersion {
+		t.Errorf("encoded record has version %d, %v", rs.version(), err)
+	}
+}
+
+func TestLegacySession(t *testing.T) {
+	at := time.Date(2017, 1, 2, 10, 0, 0, 0, time.UTC)
+	rs := rsDetailBlock{PublicKey: "old1", RP: "XYZ", RateType: "RoamingXYZ", Time: at, Duration: 3, State: stateCallEnded}
+	s, ok := legacySession(rs)
+	if !ok {
+		t.Fatal("no session for a call ended but unpaid")
+	}
+	if s.ID != legacySessionID || s.RateType != "RoamingXYZ" || s.Duration != 3 {
+		t.Errorf("session = %+v", s)
+	}
+	if !s.End.Equal(at) || !s.Start.Equal(at.Add(-3*time.Minute)) {
+		t.Errorf("session runs %s to %s, want the three minutes before %s", s.Start, s.End, at)
+	}
+
+	rs.State = stateInCall
+	if s, ok = legacySession(rs); !ok || !s.Start.Equal(at) || !s.End.IsZero() {
+		t.Errorf("call in progress gave %+v, %v", s, ok)
+	}
+	for _, state := range []string{stateRegistered, stateRatesAssigned, stateCharged} {
+		rs.State = state
+		if _, ok = legacySession(rs); ok {
+			t.Errorf("a %s subscriber has a legacy session", state)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package roaming
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Deployment environments. Demo fixtures can only be loaded outside production.
+const (
+	envProduction  = "production"
+	envDevelopment = "development"
+)
+
+// chaincodeConfig is written by the first Init. Its presence tells later
+// Inits (redeploys, upgrades) that the ledger is already seeded; its schema
+// version is that of the whole ledger once migrateSchema has finished.
+// UnenrolledAuth lets subscribers with no authentication key enrolled
+// authenticate without a proof; it is off unless turned on, and never on in
+// production.
+type chaincodeConfig struct {
+	schemaStamp
+	Environment    string      `json:"environment"`
+	SeededAt       time.Time   `json:"seededat"`
+	MaxSessions    int         `json:"maxsessions"`
+	Alerts         alertConfig `json:"alerts"`
+	UnenrolledAuth bool        `json:"unenrolledauth"`
+}
+
+// seedDocument is the optional Init argument. Fixtures asks for the demo
+// inventory on top of the listed records and is refused in production.
+type seedDocument struct {
+	Environment    string            `json:"environment"`
+	MaxSessions    int               `json:"maxsessions"`
+	Alerts         alertConfig       `json:"alerts"`
+	UnenrolledAuth bool              `json:"unenrolledauth"`
+	Fixtures       bool              `json:"fixtures"`
+	Subscribers    []subscriberInput `json:"subscribers"`
+	Operators      []operatorBlock   `json:"operators"`
+	Tariffs        []tariffBlock     `json:"tariffs"`
+	Agreements     []agreementBlock  `json:"agreements"`
+	Steering       []steeringPolicy  `json:"steering"`
+	Zones          []zoneBlock       `json:"zones"`
+	Plans          []planBlock       `json:"plans"`
+}
+
+func (t *SimpleChaincode) getConfig(stub shim.ChaincodeStubInterface) (chaincodeConfig, error) {
+	var config chaincodeConfig
+	err := getRecord(stub, "config", nil, &config)
+	return config, err
+}
+
+func (t *SimpleChaincode) putConfig(stub shim.ChaincodeStubInterface, config chaincodeConfig) error {
+	return putRecord(stub, "config", nil, &config)
+}
+
+// seed stores config and the records of doc on a fresh ledger
+func (t *SimpleChaincode) seed(stub shim.ChaincodeStubInterface, doc seedDocument) error {
+	if _, err := t.getConfig(stub); err == nil {
+		fmt.Println("Ledger already seeded, skipping seed")
+		return nil
+	}
+	if doc.Environment == "" {
+		doc.Environment = envProduction
+	}
+	if doc.Environment != envProduction && doc.Environment != envDevelopment {
+		return fmt.Errorf("unknown environment %q", doc.Environment)
+	}
+	if doc.UnenrolledAuth && doc.Environment == envProduction {
+		return errors.New("unenrolled authentication cannot be allowed in production")
+	}
+	config := chaincodeConfig{Environment: doc.Environment, SeededAt: txTime(stub), MaxSessions: doc.MaxSessions, Alerts: doc.Alerts, UnenrolledAuth: doc.UnenrolledAuth}
+	if err := t.putConfig(stub, config); err != nil {
+		return err
+	}
+	if err := t.applySeed(stub, doc); err != nil {
+		return err
+	}
+	if doc.Fixtures {
+		return t.loadFixtures(stub)
+	}
+	return nil
+}
+
+// applySeed writes every record of doc, tariffs before the agreements that
+// refer to them
+func (t *SimpleChaincode) applySeed(stub shim.ChaincodeStubInterface, doc seedDocument) error {
+	for _, op := range doc.Operators {
+		if err := t.putOperator(stub, op); err != nil {
+			return err
+		}
+	}
+	for _, tariff := range doc.Tariffs {
+		if err := t.putTariff(stub, tariff); err != nil {
+			return err
+		}
+	}
+	for _, a := range doc.Agreements {
+		if err := t.putAgreement(stub, a); err != nil {
+			return err
+		}
+	}
+	for _, z := range doc.Zones {
+		if err := t.putZone(stub, z); err != nil {
+			return err
+		}
+	}
+	for _, p := range doc.Plans {
+		if err := t.putPlan(stub, p); err != nil {
+			return err
+		}
+	}
+	for _, p := range doc.Steering {
+		if err := t.putSteeringPolicy(stub, p); err != nil {
+			return err
+		}
+	}
+	for _, in := range doc.Subscribers {
+		rs, err := t.newSubscriber(stub, nil, in.PublicKey, in.MSISDN, in.Name, in.Address, in.HO, in.Lat, in.Long)
+		if err != nil {
+			return err
+		}
+		if _, err = t.putMSIDN(stub, rs, rs.PublicKey); err != nil {
+			return err
+		}
+		if err = t.recordLocation(stub, rs, locationEntered); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Seeded %d operators, %d tariffs, %d agreements, %d zones, %d plans, %d steering policies, %d subscribers\n",
+		len(doc.Operators), len(doc.Tariffs), len(doc.Agreements), len(doc.Zones), len(doc.Plans), len(doc.Steering), len(doc.Subscribers))
+	return nil
+}
+
+// demoSeed is the demo inventory this chaincode used to hard code in Init
+func demoSeed() seedDocument {
+	return seedDocument{
+		Subscribers: []subscriberInput{
+			{"rs1", "14691234567", "A", "DC", "ABC", "32.942746", "38.91"},
+			{"rs2", "14691234568", "B", "DALLAS", "ABC", "32.942746", "-96.994838"},
+			{"rs3", "14691234569", "C", "SF", "ABC", "37.776", "-122.414"},
+			{"rs4", "03097218855", "D", "BERLIN", "XYZ", "52.5200", "13.4050"},
+			{"rs5", "349091234567", "E", "BARCELONA", "XYZ", "41.3851", "2.1734"},
+			{"rs6", "349091234568", "F", "BARCELONA", "XYZ", "41.385064", "2.173403"},
+			{"rs7", "349091234569", "G", "BARCELONA", "XYZ", "41.385064", "2.173403"},
+		},
+		Operators: []operatorBlock{
+			{Name: "ABC", MCC: "310", MNC: "099", Country: "US", MSPID: "ABCMSP", Currency: "USD", TADIG: "USAAB",
+				Contacts: []operatorContact{{Role: "roaming", Name: "ABC Roaming Desk", Email: "roaming@abc.example"}},
+				Coverage: []coverageArea{
+					{"Washington", 38.9072, -77.0369, 50},
+					{"Dallas", 32.7767, -96.7970, 80},
+					{"San Francisco", 37.7749, -122.4194, 60},
+				}},
+			{Name: "XYZ", MCC: "214", MNC: "099", Country: "ES", MSPID: "XYZMSP", Currency: "EUR", TADIG: "ESPXY", DomesticTariff: "DomesticXYZ",
+				Contacts: []operatorContact{{Role: "roaming", Name: "XYZ Roaming Desk", Email: "roaming@xyz.example"}},
+				Coverage: []coverageArea{
+					{"Barcelona", 41.3851, 2.1734, 40},
+					{"Madrid", 40.4168, -3.7038, 50},
+				}},
+			{Name: "LMN", MCC: "214", MNC: "098", Country: "ES", MSPID: "LMNMSP", Currency: "EUR", TADIG: "ESPLM",
+				Contacts: []operatorContact{{Role: "roaming", Name: "LMN Roaming Desk", Email: "roaming@lmn.example"}},
+				Coverage: []coverageArea{
+					{"Barcelona", 41.3851, 2.1734, 25},
+				}},
+			{Name: "GHI", MCC: "262", MNC: "099", Country: "DE", MSPID: "GHIMSP", Currency: "EUR", TADIG: "DEUGH",
+				Contacts: []operatorContact{{Role: "roaming", Name: "GHI Roaming Desk", Email: "roaming@ghi.example"}},
+				Coverage: []coverageArea{
+					{"Berlin", 52.5200, 13.4050, 40},
+				}},
+		},
+		Tariffs: []tariffBlock{
+			{ID: "RoamingXYZ", Currency: "USD", VoicePerMin: 5, VoiceInPerMin: 1, DataPerMB: 2, SMS: 0.5},
+			{ID: "RoamingABC", Currency: "USD", VoicePerMin: 5, VoiceInPerMin: 1, DataPerMB: 2, SMS: 0.5},
+			{ID: "RoamingLMN", Currency: "USD", VoicePerMin: 4, VoiceInPerMin: 1, DataPerMB: 3, SMS: 0.4},
+			{ID: "RoamingGHI", Currency: "USD", VoicePerMin: 3, VoiceInPerMin: 1, DataPerMB: 4, SMS: 0.3},
+			{ID: "DomesticXYZ", Currency: "USD", VoicePerMin: 0.2, DataPerMB: 0.5, SMS: 0.1},
+		},
+		Agreements: []agreementBlock{
+			{HO: "ABC", RP: "XYZ", Tariff: "RoamingXYZ"},
+			{HO: "XYZ", RP: "ABC", Tariff: "RoamingABC"},
+			{HO: "ABC", RP: "LMN", Tariff: "RoamingLMN"},
+			{HO: "XYZ", RP: "GHI", Tariff: "RoamingGHI"},
+		},
+		Zones: []zoneBlock{
+			{ID: "EU", Name: "European Union", Countries: []string{"AT", "BE", "DE", "ES", "FR", "IE", "IT", "NL", "PT"},
+				RLAH: true, FairUseMB: 1000, SurchargePerMB: 0.003},
+		},
+		Plans: []planBlock{
+			{ID: "ABC-Travel", HO: "ABC", Name: "Travel", CycleDays: 30, Allowances: allowances{
+				VoiceOutL: 100, VoiceInL: 100, DataL: 1000, VoiceOutR: 2, VoiceInR: 5, DataR: 5}},
+		},
+		Steering: []steeringPolicy{
+			{HO: "ABC", Country: "ES", Preferred: []preferredPartner{{"XYZ", 1}}, MaxRejects: 2},
+		},
+	}
+}
+
+// loadFixtures (re)writes the demo inventory. It is refused in production.
+func (t *SimpleChaincode) loadFixtures(stub shim.ChaincodeStubInterface) error {
+	config, err := t.getConfig(stub)
+	if err != nil {
+		return errors.New("chaincode is not initialised")
+	}
+	if config.Environment == envProduction {
+		return errors.New("demo fixtures cannot be loaded in production")
+	}
+	doc := demoSeed()
+	if err = t.applySeed(stub, doc); err != nil {
+		return err
+	}
+
+	rsmap = make(map[string]string)
+	for _, in := range doc.Subscribers {
+		rsmap[in.PublicKey] = in.MSISDN
+	}
+	rsmap["rs8"] = ""
+	return nil
+}
+
+//Set Session Limit: change how many sessions a subscriber may have in progress
+func (t *SimpleChaincode) setSessionLimit(stub shim.ChaincodeStubInterface, limit string) ([]byte, error) {
+	if err := callerIsAdmin(stub); err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(limit)
+	if err != nil || n < 1 {
+		return nil, errors.New("session limit must be a positive number")
+	}
+	config, err := t.getConfig(stub)
+	if err != nil {
+		return nil, errors.New("chaincode is not initialised")
+	}
+	config.MaxSessions = n
+	if err = t.putConfig(stub, config); err != nil {
+		return nil, err
+	}
+	resp := newInvokeResponse(stub, "setSessionLimit", nil)
+	resp.Result = config
+	return resp.marshal()
+}
+
+//Set Unenrolled Auth: allow or stop subscribers with no authentication key
+//enrolled authenticating without a proof. Refused in production.
+func (t *SimpleChaincode) setUnenrolledAuth(stub shim.ChaincodeStubInterface, allow string) ([]byte, error) {
+	if err := callerIsAdmin(stub); err != nil {
+		return nil, err
+	}
+	on, err := strconv.ParseBool(allow)
+	if err != nil {
+		return nil, errors.New("expecting true or false")
+	}
+	config, err := t.getConfig(stub)
+	if err != nil {
+		return nil, errors.New("chaincode is not initialised")
+	}
+	if on && config.Environment == envProduction {
+		return nil, errors.New("unenrolled authentication cannot be allowed in production")
+	}
+	config.UnenrolledAuth = on
+	if err = t.putConfig(stub, config); err != nil {
+		return nil, err
+	}
+	resp := newInvokeResponse(stub, "setUnenrolledAuth", nil)
+	resp.Result = config
+	return resp.marshal()
+}
+
+// parseSeed reads the optional Init argument
+func parseSeed(args []string) (seedDocument, error) {
+	var doc seedDocument
+	if len(args) == 0 || args[0] == "" {
+		return doc, nil
+	}
+	if err := json.Unmarshal([]byte(args[0]), &doc); err != nil {
+		return doc, fmt.Errorf("invalid seed document: %s", err)
+	}
+	return doc, nil
+}
@@ -0,0 +1,60 @@
+package roaming
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+func TestParseSeed(t *testing.T) {
+	doc, err := parseSeed(nil)
+	if err != nil || doc.Environment != "" || doc.Fixtures {
+		t.Errorf("parseSeed(nil) = %+v, %v", doc, err)
+	}
+	doc, err = parseSeed([]string{`{"environment":"development","fixtures":true,"maxsessions":2}`})
+	if err != nil || doc.Environment != envDevelopment || !doc.Fixtures || doc.MaxSessions != 2 {
+		t.Errorf("parseSeed = %+v, %v", doc, err)
+	}
+	if _, err = parseSeed([]string{`{"environment":`}); err == nil || !strings.Contains(err.Error(), "invalid seed document") {
+		t.Errorf("parseSeed of malformed JSON returned %v", err)
+	}
+}
+
+func TestInitRefuses(t *testing.T) {
+	tests := []struct {
+		seed string
+		want string
+	}{
+		{`{"environment":"staging"}`, `unknown environment "staging"`},
+		{`{"fixtures":true}`, "demo fixtures cannot be loaded in production"},
+		{`{"environment":"production","unenrolledauth":true}`, "cannot be allowed in production"},
+	}
+	for _, tt := range tests {
+		stub := shim.NewMockStub("seed", new(SimpleChaincode))
+		if _, err := stub.MockInit("init", "init", []string{tt.seed}); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Init(%s) returned %v, want an error containing %q", tt.seed, err, tt.want)
+		}
+	}
+}
+
+func TestInitSeedsOnce(t *testing.T) {
+	cc := new(SimpleChaincode)
+	stub := shim.NewMockStub("seed", cc)
+	if _, err := stub.MockInit("init1", "init", []string{`{"environment":"development","maxsessions":2}`}); err != nil {
+		t.Fatal(err)
+	}
+	// a redeploy keeps the ledger as it is
+	if _, err := stub.MockInit("init2", "init", []string{`{"environment":"production","maxsessions":5}`}); err != nil {
+		t.Fatal(err)
+	}
+	stub.MockTransactionStart("check")
+	defer stub.MockTransactionEnd("check")
+	config, err := cc.getConfig(stub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Environment != envDevelopment || config.MaxSessions != 2 {
+		t.Errorf("config after redeploy is %+v", config)
+	}
+}
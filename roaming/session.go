@@ -0,0 +1,109 @@
+package roaming
+
+import "fmt"
+
+// Roaming session states, in the order a subscriber normally goes through
+// them. Charged subscribers may start another call or move on to a new
+// partner network. The last three are also the states of each call session;
+// a subscriber with several sessions is InCall while any of them is, then
+// CallEnded until every session is Charged.
+const (
+	stateRegistered    = "Registered"
+	stateDiscovered    = "Discovered"
+	stateAuthenticated = "Authenticated"
+	stateRatesAssigned = "RatesAssigned"
+	stateInCall        = "InCall"
+	stateCallEnded     = "CallEnded"
+	stateCharged       = "Charged"
+)
+
+type transition struct {
+	from []string
+	to   string
+}
+
+// sessionTransitions lists, for each roaming function, the states it may
+// be invoked in and the state it leaves the subscriber in
+var sessionTransitions = map[string]transition{
+	"discoverRP":     {[]string{stateRegistered, stateDiscovered, stateAuthenticated, stateRatesAssigned, stateCharged}, stateDiscovered},
+	"authentication": {[]string{stateDiscovered, stateAuthenticated}, stateAuthenticated},
+	"updateRates":    {[]string{stateAuthenticated, stateRatesAssigned, stateCharged}, stateRatesAssigned},
+	"CallOut":        {[]string{stateRatesAssigned, stateInCall, stateCallEnded, stateCharged}, stateInCall},
+}
+
+// callTransitions does the same for the functions acting on one call session
+var callTransitions = map[string]transition{
+	"CallEnd": {[]string{stateInCall}, stateCallEnded},
+	"CallPay": {[]string{stateCallEnded}, stateCharged},
+}
+
+// legacyStates maps the Action written by handlers before State existed
+var legacyStates = map[string]string{
+	"Discovery":           stateDiscovered,
+	"Authentication":      stateAuthenticated,
+	"Register":            stateRatesAssigned,
+	"Call Initialization": stateInCall,
+	"Call End":            stateCallEnded,
+	"Pay Charge":          stateCharged,
+}
+
+// sessionState returns the state of rs, deriving it from the last Action
+// for records written before State was stored
+func sessionState(rs rsDetailBlock) string {
+	if rs.State != "" {
+		return rs.State
+	}
+	if state, ok := legacyStates[rs.Action]; ok {
+		return state
+	}
+	return stateRegistered
+}
+
+// checkTransition rejects function if rs is not in a state it may be invoked in
+func checkTransition(rs rsDetailBlock, function string) error {
+	current := sessionState(rs)
+	for _, s := range sessionTransitions[function].from {
+		if s == current {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s not allowed: subscriber %s is %s, expected one of %v",
+		function, rs.PublicKey, current, sessionTransitions[function].from)
+}
+
+// advanceState checks function may run on rs and moves rs to the state it
+// leads to
+func advanceState(rs *rsDetailBlock, function string) error {
+	if err := checkTransition(*rs, function); err != nil {
+		return err
+	}
+	rs.State = sessionTransitions[function].to
+	return nil
+}
+
+// advanceSession checks function may run on session s and moves s to the
+// state it leads to
+func advanceSession(s *sessionBlock, function string) error {
+	tr := callTransitions[function]
+	for _, from := range tr.from {
+		if from == s.State {
+			s.State = tr.to
+			return nil
+		}
+	}
+	return fmt.Errorf("%s not allowed: session %s is %s, expected one of %v", function, s.ID, s.State, tr.from)
+}
+
+// summaryState is the subscriber state implied by its call sessions
+func summaryState(sessions []sessionBlock) string {
+	state := stateCharged
+	for _, s := range sessions {
+		if s.State == stateInCall {
+			return stateInCall
+		}
+		if s.State == stateCallEnded {
+			state = stateCallEnded
+		}
+	}
+	return state
+}
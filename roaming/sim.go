@@ -0,0 +1,196 @@
+package roaming
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// SIM states. Subscribers entered before SIMs were recorded have none and
+// are not restricted by it.
+const (
+	simActive   = "ACTIVE"
+	simBlocked  = "BLOCKED"
+	simInactive = "INACTIVE"
+)
+
+var simStates = []string{simActive, simBlocked, simInactive}
+
+// The imsi index maps the identity networks authenticate by to the
+// subscriber's key, the same way as the msisdn index:
+//
+//	\x00imsi\x00<IMSI>\x00<PublicKey>\x00 -> empty value
+const imsiIndex = "imsi"
+
+// imsiParts is an IMSI split into the home network's MCC and MNC and the
+// subscriber's MSIN
+type imsiParts struct {
+	MCC  string `json:"mcc"`
+	MNC  string `json:"mnc"`
+	MSIN string `json:"msin"`
+}
+
+func allDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// parseIMSI splits imsi and returns the registered operator it belongs to.
+// Whether the MNC has two or three digits depends on the network, so the
+// three digit form is looked up first.
+func (t *SimpleChaincode) parseIMSI(stub shim.ChaincodeStubInterface, imsi string) (imsiParts, operatorBlock, error) {
+	if len(imsi) < 6 || len(imsi) > 15 || !allDigits(imsi) {
+		return imsiParts{}, operatorBlock{}, fmt.Errorf("IMSI %s must be 6 to 15 digits", imsi)
+	}
+	for _, mncLen := range []int{3, 2} {
+		parts := imsiParts{MCC: imsi[:3], MNC: imsi[3 : 3+mncLen], MSIN: imsi[3+mncLen:]}
+		if op, err := t.operatorByIndex(stub, operatorPLMNIndex, parts.MCC+"-"+parts.MNC); err == nil {
+			return parts, op, nil
+		}
+	}
+	return imsiParts{}, operatorBlock{}, fmt.Errorf("no operator registered for IMSI %s", imsi)
+}
+
+// checkICCID validates a SIM serial number: 19 or 20 digits starting with
+// the telecom industry prefix 89
+func checkICCID(iccid string) error {
+	if (len(iccid) != 19 && len(iccid) != 20) || !allDigits(iccid) || iccid[:2] != "89" {
+		return fmt.Errorf("ICCID %s must be 19 or 20 digits starting with 89", iccid)
+	}
+	return nil
+}
+
+// imsiHolder returns the key of the non-terminated subscriber holding imsi,
+// or "" if there is none
+func (t *SimpleChaincode) imsiHolder(stub shim.ChaincodeStubInterface, imsi string) (string, error) {
+	keys, err := indexKeys(stub, imsiIndex, []string{imsi})
+	if err != nil {
+		return "", err
+	}
+	for _, key := range keys {
+		rs, err := t.getSubscriber(stub, key)
+		if err != nil {
+			return "", err
+		}
+		if rs.IMSI == imsi && subscriberStatus(rs) != statusTerminated {
+			return key, nil
+		}
+	}
+	return "", nil
+}
+
+// imsiPrefix marks a subscriber id given as an IMSI, e.g. imsi:310099123456789
+const imsiPrefix = "imsi:"
+
+// resolveKey lets functions taking a subscriber key be invoked with the
+// subscriber's IMSI instead, given with imsiPrefix. Any other id is a key.
+func (t *SimpleChaincode) resolveKey(stub shim.ChaincodeStubInterface, id string) string {
+	if !strings.HasPrefix(id, imsiPrefix) {
+		return id
+	}
+	if key, err := t.imsiHolder(stub, strings.TrimPrefix(id, imsiPrefix)); err == nil && key != "" {
+		return key
+	}
+	return id
+}
+
+//Assign SIM: the home operator records the IMSI and ICCID of a subscriber's
+//SIM, replacing any earlier SIM, and activates it. The IMSI must belong to
+//the subscriber's home network.
+func (t *SimpleChaincode) assignSIM(stub shim.ChaincodeStubInterface, key string, imsi string, iccid string) ([]byte, error) {
+	rsDetailobj, err := t.getSubscriber(stub, key)
+	if err != nil {
+		return nil, err
+	}
+	caller, err := callerOperator(stub)
+	if err != nil {
+		return nil, err
+	}
+	if caller != rsDetailobj.HO {
+		return nil, fmt.Errorf("only home operator %s may assign SIMs to %s", rsDetailobj.HO, key)
+	}
+	if subscriberStatus(rsDetailobj) == statusTerminated {
+		return nil, fmt.Errorf("subscriber %s is terminated", key)
+	}
+	parts, op, err := t.parseIMSI(stub, imsi)
+	if err != nil {
+		return nil, err
+	}
+	if op.Name != rsDetailobj.HO {
+		return nil, fmt.Errorf("IMSI %s belongs to %s, not home operator %s", imsi, op.Name, rsDetailobj.HO)
+	}
+	if err = checkICCID(iccid); err != nil {
+		return nil, err
+	}
+	holder, err := t.imsiHolder(stub, imsi)
+	if err != nil {
+		return nil, err
+	}
+	if holder != "" && holder != key {
+		return nil, fmt.Errorf("IMSI %s is already held by %s", imsi, holder)
+	}
+
+	rsDetailobj.IMSI = imsi
+	rsDetailobj.ICCID = iccid
+	rsDetailobj.SIMState = simActive
+	if _, err = t.putMSIDN(stub, rsDetailobj, key); err != nil {
+		return nil, err
+	}
+	resp := newInvokeResponse(stub, "assignSIM", &rsDetailobj)
+	resp.Result = parts
+	return resp.marshal()
+}
+
+//Set SIM State: the home operator blocks a lost or stolen SIM, or activates
+//it again
+func (t *SimpleChaincode) setSIMState(stub shim.ChaincodeStubInterface, key string, state string) ([]byte, error) {
+	rsDetailobj, err := t.getSubscriber(stub, key)
+	if err != nil {
+		return nil, err
+	}
+	caller, err := callerOperator(stub)
+	if err != nil {
+		return nil, err
+	}
+	if caller != rsDetailobj.HO {
+		return nil, fmt.Errorf("only home operator %s may change the SIM of %s", rsDetailobj.HO, key)
+	}
+	if rsDetailobj.IMSI == "" {
+		return nil, fmt.Errorf("subscriber %s has no SIM assigned", key)
+	}
+	if !containsString(simStates, state) {
+		return nil, fmt.Errorf("unknown SIM state %q, expected one of %v", state, simStates)
+	}
+	rsDetailobj.SIMState = state
+	bytes, _ := encodeSubscriber(rsDetailobj)
+	if err = stub.PutState(key, bytes); err != nil {
+		return nil, err
+	}
+	return newInvokeResponse(stub, "setSIMState", &rsDetailobj).marshal()
+}
+
+//Query a subscriber by IMSI rather than by PublicKey
+func (t *SimpleChaincode) queryByIMSI(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) < 1 {
+		return nil, errors.New("Incorrect number of arguments. Expecting IMSI")
+	}
+	key, err := t.imsiHolder(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if key == "" {
+		return nil, fmt.Errorf("no active subscriber holds IMSI %s", args[0])
+	}
+	rs, err := t.getSubscriber(stub, key)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(rs)
+}
@@ -0,0 +1,65 @@
+package roaming
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseIMSI(t *testing.T) {
+	stub := newTestStub()
+	cc := new(SimpleChaincode)
+	for _, op := range []operatorBlock{
+		{Name: "ABC", MCC: "310", MNC: "099", MSPID: "ABCMSP"},
+		{Name: "OPQ", MCC: "310", MNC: "09", MSPID: "OPQMSP"},
+		{Name: "VOD", MCC: "234", MNC: "15", MSPID: "VODMSP"},
+	} {
+		if err := cc.putOperator(stub, op); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		imsi     string
+		operator string
+		parts    imsiParts
+	}{
+		{"310099000000001", "ABC", imsiParts{"310", "099", "000000001"}},
+		// no 310-091 network, so the MNC is the two digits 09
+		{"310091234567890", "OPQ", imsiParts{"310", "09", "1234567890"}},
+		{"234151234567", "VOD", imsiParts{"234", "15", "1234567"}},
+	}
+	for _, tt := range tests {
+		parts, op, err := cc.parseIMSI(stub, tt.imsi)
+		if err != nil {
+			t.Errorf("parseIMSI(%s): %v", tt.imsi, err)
+			continue
+		}
+		if op.Name != tt.operator || parts != tt.parts {
+			t.Errorf("parseIMSI(%s) = %+v of %s, want %+v of %s", tt.imsi, parts, op.Name, tt.parts, tt.operator)
+		}
+	}
+
+	for imsi, want := range map[string]string{
+		"31009":            "must be 6 to 15 digits",
+		"3100990000000012": "must be 6 to 15 digits",
+		"31009900000000A":  "must be 6 to 15 digits",
+		"208011234567890":  "no operator registered for IMSI 208011234567890",
+	} {
+		if _, _, err := cc.parseIMSI(stub, imsi); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("parseIMSI(%s) returned %v, want %q", imsi, err, want)
+		}
+	}
+}
+
+func TestCheckICCID(t *testing.T) {
+	for _, iccid := range []string{"8901000000000000001", "89340000000000000012"} {
+		if err := checkICCID(iccid); err != nil {
+			t.Errorf("checkICCID(%s): %v", iccid, err)
+		}
+	}
+	for _, iccid := range []string{"", "890100000000000001", "891000000000000000123", "7901000000000000001", "89010000000000000A1"} {
+		if err := checkICCID(iccid); err == nil {
+			t.Errorf("checkICCID(%s) accepted it", iccid)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package roaming
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Steering outcomes of an attach attempt
+const (
+	steerUnsteered = "UNSTEERED"
+	steerPreferred = "PREFERRED"
+	steerRejected  = "REJECTED"
+	steerAllowed   = "ALLOWED"
+)
+
+// preferredPartner is a partner a home operator steers its roamers to.
+// Lower Priority is preferred first.
+type preferredPartner struct {
+	RP       string `json:"rp"`
+	Priority int    `json:"priority"`
+}
+
+// steeringPolicy is a home operator's preference among the partners of one
+// country, stored under ("steering", HO, Country). Attaching to a partner
+// that is not preferred is rejected MaxRejects times, then allowed, so a
+// roamer out of reach of every preferred network is not left without
+// service.
+type steeringPolicy struct {
+	schemaStamp
+	HO         string             `json:"ho"`
+	Country    string             `json:"country"`
+	Preferred  []preferredPartner `json:"preferred"`
+	MaxRejects int                `json:"maxrejects"`
+}
+
+// byPriority orders preferred partners, most preferred first
+type byPriority []preferredPartner
+
+func (p byPriority) Len() int           { return len(p) }
+func (p byPriority) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p byPriority) Less(i, j int) bool { return p[i].Priority < p[j].Priority }
+
+func (p steeringPolicy) prefers(rp string) bool {
+	for _, partner := range p.Preferred {
+		if partner.RP == rp {
+			return true
+		}
+	}
+	return false
+}
+
+// steeringAttempts counts a subscriber's rejected attaches in a country
+// since it last attached there, under ("steerattempts", key, country)
+type steeringAttempts struct {
+	schemaStamp
+	Key      string `json:"key"`
+	Country  string `json:"country"`
+	Rejected int    `json:"rejected"`
+}
+
+// steeringOutcome is recorded for every steered attach attempt under
+// ("steered", HO, country, txid) for reporting
+type steeringOutcome struct {
+	schemaStamp
+	HO      string    `json:"ho"`
+	Country string    `json:"country"`
+	Key     string    `json:"key"`
+	RP      string    `json:"rp"`
+	Outcome string    `json:"outcome"`
+	Attempt int       `json:"attempt"`
+	TxID    string    `json:"txid"`
+	Time    time.Time `json:"time"`
+}
+
+func (t *SimpleChaincode) putSteeringPolicy(stub shim.ChaincodeStubInterface, p steeringPolicy) error {
+	if p.HO == "" || p.Country == "" {
+		return errors.New("steering policy needs ho and country")
+	}
+	if p.MaxRejects < 0 {
+		return errors.New("maxrejects cannot be negative")
+	}
+	for _, partner := range p.Preferred {
+		if partner.RP == "" || partner.RP == p.HO {
+			return fmt.Errorf("%q cannot be a preferred partner of %s", partner.RP, p.HO)
+		}
+	}
+	sort.Stable(byPriority(p.Preferred))
+	return putRecord(stub, "steering", []string{p.HO, p.Country}, &p)
+}
+
+func (t *SimpleChaincode) getSteeringPolicy(stub shim.ChaincodeStubInterface, ho string, country string) (steeringPolicy, error) {
+	var p steeringPolicy
+	err := getRecord(stub, "steering", []string{ho, country}, &p)
+	return p, err
+}
+
+// steer decides whether rs may attach to rp and records the attempt. It
+// returns the outcome and, for a rejected attempt, the policy steered by.
+func (t *SimpleChaincode) steer(stub shim.ChaincodeStubInterface, rs rsDetailBlock, rp string) (string, steeringPolicy, error) {
+	var policy steeringPolicy
+	op, err := t.getOperator(stub, rp)
+	if err != nil || rp == rs.HO {
+		return steerUnsteered, policy, nil
+	}
+	if policy, err = t.getSteeringPolicy(stub, rs.HO, op.Country); err != nil {
+		return steerUnsteered, policy, nil
+	}
+
+	var attempts steeringAttempts
+	if err = getRecord(stub, "steerattempts", []string{rs.PublicKey, op.Country}, &attempts); err != nil {
+		attempts = steeringAttempts{Key: rs.PublicKey, Country: op.Country}
+	}
+	outcome := steerPreferred
+	if !policy.prefers(rp) {
+		outcome = steerAllowed
+		if attempts.Rejected < policy.MaxRejects {
+			outcome = steerRejected
+		}
+	}
+	attempt := attempts.Rejected + 1
+	if outcome == steerRejected {
+		attempts.Rejected++
+	} else {
+		attempts.Rejected = 0
+	}
+	if err = putRecord(stub, "steerattempts", []string{rs.PublicKey, op.Country}, &attempts); err != nil {
+		return "", policy, err
+	}
+
+	txid := stub.GetTxID()
+	record := steeringOutcome{
+		HO:      rs.HO,
+		Country: op.Country,
+		Key:     rs.PublicKey,
+		RP:      rp,
+		Outcome: outcome,
+		Attempt: attempt,
+		TxID:    txid,
+		Time:    txTime(stub),
+	}
+	if err = putRecord(stub, "steered", []string{rs.HO, op.Country, txid}, &record); err != nil {
+		return "", policy, err
+	}
+	return outcome, policy, nil
+}
+
+//Set Steering Policy: a home operator sets its preferred partners in a country
+//args: policy JSON e.g. {"country":"ES","preferred":[{"rp":"XYZ","priority":1}],"maxrejects":2}
+func (t *SimpleChaincode) setSteeringPolicy(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) < 1 {
+		return nil, errors.New("Incorrect number of arguments. Expecting policy JSON")
+	}
+	var p steeringPolicy
+	if err := json.Unmarshal([]byte(args[0]), &p); err != nil {
+		return nil, fmt.Errorf("invalid steering policy: %s", err)
+	}
+	caller, err := callerOperator(stub)
+	if err != nil {
+		return nil, err
+	}
+	if p.HO == "" {
+		p.HO = caller
+	}
+	if p.HO != caller {
+		return nil, fmt.Errorf("only %s may set its steering policy", p.HO)
+	}
+	if err = t.putSteeringPolicy(stub, p); err != nil {
+		return nil, err
+	}
+	resp := newInvokeResponse(stub, "setSteeringPolicy", nil)
+	resp.Result = p
+	return resp.marshal()
+}
+
+// steeringReport summarises the steered attach attempts of a home operator
+type steeringReport struct {
+	HO       string            `json:"ho"`
+	Country  string            `json:"country,omitempty"`
+	Outcomes map[string]int    `json:"outcomes"`
+	Attempts []steeringOutcome `json:"attempts"`
+}
+
+//Query Steering: report the steering outcomes of a home operator
+//args: ho, optional country
+func (t *SimpleChaincode) querySteering(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if len(args) < 1 {
+		return nil, errors.New("Incorrect number of arguments. Expecting HO and optional country")
+	}
+	attrs := []string{args[0]}
+	report := steeringReport{HO: args[0], Outcomes: map[string]int{}, Attempts: []steeringOutcome{}}
+	if len(args) > 1 && args[1] != "" {
+		attrs = append(attrs, args[1])
+		report.Country = args[1]
+	}
+	prefix, err := createCompositeKey("steered", attrs)
+	if err != nil {
+		return nil, err
+	}
+	iter, err := stub.RangeQueryState(prefix, prefix+maxUnicodeRune)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	for iter.HasNext() {
+		_, bytes, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		var record steeringOutcome
+		if err = json.Unmarshal(bytes, &record); err != nil {
+			return nil, err
+		}
+		report.Outcomes[record.Outcome]++
+		report.Attempts = append(report.Attempts, record)
+	}
+	return json.Marshal(report)
+}
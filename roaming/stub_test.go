@@ -0,0 +1,13 @@
+package roaming
+
+import (
+	"github.com/amanrubal/ChaincodeUpload/internal/shimtest"
+)
+
+// newTestStub returns a stub in an open transaction whose range scans keep
+// to their bounds, as the peer's do
+func newTestStub() *shimtest.Stub {
+	s := shimtest.NewStub("test", nil)
+	s.MockTransactionStart("tx1")
+	return s
+}
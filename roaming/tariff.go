@@ -0,0 +1,188 @@
+package roaming
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Prices charged when a subscriber's RateType does not name a stored tariff
+const (
+	defaultVoiceRate = 5.0
+	defaultDataRate  = 2.0
+	defaultSMSRate   = 0.5
+)
+
+// coverageArea is a circle of RadiusKm around Lat, Long where an operator's
+// network can be attached to
+type coverageArea struct {
+	Name     string  `json:"name"`
+	Lat      float64 `json:"lat"`
+	Long     float64 `json:"long"`
+	RadiusKm float64 `json:"radiuskm"`
+}
+
+// tariffBlock prices usage on a visited network
+type tariffBlock struct {
+	schemaStamp
+	ID            string  `json:"id"`
+	Currency      string  `json:"currency"`
+	VoicePerMin   float64 `json:"voicepermin"`
+	VoiceInPerMin float64 `json:"voiceinpermin"`
+	DataPerMB     float64 `json:"datapermb"`
+	SMS           float64 `json:"sms"`
+}
+
+// agreementBlock lets subscribers of HO roam on RP, rated with Tariff.
+// A zero ValidFrom or ValidTo leaves that end open.
+type agreementBlock struct {
+	schemaStamp
+	HO        string    `json:"ho"`
+	RP        string    `json:"rp"`
+	Tariff    string    `json:"tariff"`
+	ValidFrom time.Time `json:"validfrom"`
+	ValidTo   time.Time `json:"validto"`
+}
+
+func (a agreementBlock) validAt(when time.Time) bool {
+	if !a.ValidFrom.IsZero() && when.Before(a.ValidFrom) {
+		return false
+	}
+	if !a.ValidTo.IsZero() && when.After(a.ValidTo) {
+		return false
+	}
+	return true
+}
+
+// putRecord stores v, a pointer to a stored object, as JSON under a
+// composite key in the current schema version
+func putRecord(stub shim.ChaincodeStubInterface, objectType string, attributes []string, v interface{}) error {
+	key, err := createCompositeKey(objectType, attributes)
+	if err != nil {
+		return err
+	}
+	if obj, ok := v.(interface{ stamp() }); ok {
+		obj.stamp()
+	}
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(key, bytes)
+}
+
+// getRecord loads the JSON stored under a composite key into v
+func getRecord(stub shim.ChaincodeStubInterface, objectType string, attributes []string, v interface{}) error {
+	key, err := createCompositeKey(objectType, attributes)
+	if err != nil {
+		return err
+	}
+	bytes, err := stub.GetState(key)
+	if err != nil {
+		return err
+	}
+	if bytes == nil {
+		return fmt.Errorf("no %s %v", objectType, attributes)
+	}
+	if err = json.Unmarshal(bytes, v); err != nil {
+		return err
+	}
+	return checkVersion(v)
+}
+
+// listRecords loads every record stored under objectType, in key order, into
+// the value next returns for it
+func listRecords(stub shim.ChaincodeStubInterface, objectType string, next func() interface{}) error {
+	prefix, err := createCompositeKey(objectType, nil)
+	if err != nil {
+		return err
+	}
+	iter, err := stub.RangeQueryState(prefix, prefix+maxUnicodeRune)
+	if err != nil {
+		return err
+	}
+	defer iter.Close()
+
+	for iter.HasNext() {
+		_, bytes, err := iter.Next()
+		if err != nil {
+			return err
+		}
+		v := next()
+		if err = json.Unmarshal(bytes, v); err != nil {
+			return err
+		}
+		if err = checkVersion(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *SimpleChaincode) putTariff(stub shim.ChaincodeStubInterface, tariff tariffBlock) error {
+	if tariff.ID == "" {
+		return fmt.Errorf("tariff has no id")
+	}
+	return putRecord(stub, "tariff", []string{tariff.ID}, &tariff)
+}
+
+func (t *SimpleChaincode) getTariff(stub shim.ChaincodeStubInterface, id string) (tariffBlock, error) {
+	var tariff tariffBlock
+	err := getRecord(stub, "tariff", []string{id}, &tariff)
+	return tariff, err
+}
+
+func (t *SimpleChaincode) putAgreement(stub shim.ChaincodeStubInterface, a agreementBlock) error {
+	if a.HO == "" || a.RP == "" || a.Tariff == "" {
+		return fmt.Errorf("agreement needs ho, rp and tariff")
+	}
+	if _, err := t.getTariff(stub, a.Tariff); err != nil {
+		return err
+	}
+	return putRecord(stub, "agreement", []string{a.HO, a.RP}, &a)
+}
+
+// getAgreement returns the agreement between ho and rp valid at when
+func (t *SimpleChaincode) getAgreement(stub shim.ChaincodeStubInterface, ho string, rp string, when time.Time) (agreementBlock, error) {
+	var a agreementBlock
+	if err := getRecord(stub, "agreement", []string{ho, rp}, &a); err != nil {
+		return a, err
+	}
+	if !a.validAt(when) {
+		return a, fmt.Errorf("agreement between %s and %s is not valid at %s", ho, rp, when.Format(time.RFC822))
+	}
+	return a, nil
+}
+
+// rates returns the tariff named by rateType, or the default prices
+func (t *SimpleChaincode) rates(stub shim.ChaincodeStubInterface, rateType string) tariffBlock {
+	if rateType != "" {
+		if tariff, err := t.getTariff(stub, rateType); err == nil {
+			return tariff
+		}
+	}
+	return tariffBlock{VoicePerMin: defaultVoiceRate, DataPerMB: defaultDataRate, SMS: defaultSMSRate}
+}
+
+// roamingRateType is the tariff updateRates rates rs with at when. Roaming
+// in a Roam-Like-At-Home zone it is the home operator's domestic tariff,
+// else that of the agreement between its HO and RP, else the partner's
+// fixed roaming tariff. Otherwise rs keeps its RateType.
+func (t *SimpleChaincode) roamingRateType(stub shim.ChaincodeStubInterface, rs rsDetailBlock, when time.Time) string {
+	if rs.Roaming != "True" {
+		return rs.RateType
+	}
+	sp := rs.RP
+	if _, ok := t.rlahZone(stub, rs.HO, sp); ok {
+		return t.domesticTariff(stub, rs.HO)
+	} else if agreement, err := t.getAgreement(stub, rs.HO, sp, when); err == nil {
+		return agreement.Tariff
+	} else if sp == "XYZ" {
+		return "RoamingXYZ"
+	} else if sp == "ABC" {
+		return "RoamingABC"
+	}
+	return rs.RateType
+}
@@ -0,0 +1,152 @@
+package roaming
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// zoneBlock groups countries and operators, stored under ("zone", ID).
+// In a Roam-Like-At-Home zone, subscribers whose home operator is in the
+// zone are charged their domestic tariff on any other network of the zone.
+// Data beyond FairUseMB in a calendar month carries SurchargePerMB on top.
+type zoneBlock struct {
+	schemaStamp
+	ID             string   `json:"id"`
+	Name           string   `json:"name"`
+	Countries      []string `json:"countries"`
+	Operators      []string `json:"operators"`
+	RLAH           bool     `json:"rlah"`
+	FairUseMB      float64  `json:"fairusemb"`
+	SurchargePerMB float64  `json:"surchargepermb"`
+}
+
+// includes reports whether op belongs to the zone
+func (z zoneBlock) includes(op operatorBlock) bool {
+	return containsString(z.Operators, op.Name) || containsString(z.Countries, op.Country)
+}
+
+// fairUseCounter is the RLAH data a subscriber used in a zone in one
+// month, under ("fairuse", key, zone, "2006-01")
+type fairUseCounter struct {
+	schemaStamp
+	Key    string  `json:"key"`
+	Zone   string  `json:"zone"`
+	Period string  `json:"period"`
+	DataMB float64 `json:"datamb"`
+}
+
+func fairUsePeriod(when time.Time) string {
+	return when.Format("2006-01")
+}
+
+func (t *SimpleChaincode) putZone(stub shim.ChaincodeStubInterface, z zoneBlock) error {
+	if z.ID == "" {
+		return errors.New("zone has no id")
+	}
+	if z.FairUseMB < 0 || z.SurchargePerMB < 0 {
+		return errors.New("fair use allowance and surcharge cannot be negative")
+	}
+	return putRecord(stub, "zone", []string{z.ID}, &z)
+}
+
+func (t *SimpleChaincode) getZone(stub shim.ChaincodeStubInterface, id string) (zoneBlock, error) {
+	var z zoneBlock
+	err := getRecord(stub, "zone", []string{id}, &z)
+	return z, err
+}
+
+func (t *SimpleChaincode) listZones(stub shim.ChaincodeStubInterface) ([]zoneBlock, error) {
+	var zones []zoneBlock
+	err := listRecords(stub, "zone", func() interface{} {
+		zones = append(zones, zoneBlock{})
+		return &zones[len(zones)-1]
+	})
+	return zones, err
+}
+
+// rlahZone returns the Roam-Like-At-Home zone holding both ho and rp, if
+// any. Roaming there is rated with the domestic tariff of ho, so ho must
+// have one.
+func (t *SimpleChaincode) rlahZone(stub shim.ChaincodeStubInterface, ho string, rp string) (zoneBlock, bool) {
+	if rp == "" || rp == ho {
+		return zoneBlock{}, false
+	}
+	home, err := t.getOperator(stub, ho)
+	if err != nil || home.DomesticTariff == "" {
+		return zoneBlock{}, false
+	}
+	visited, err := t.getOperator(stub, rp)
+	if err != nil {
+		return zoneBlock{}, false
+	}
+	zones, err := t.listZones(stub)
+	if err != nil {
+		return zoneBlock{}, false
+	}
+	for _, z := range zones {
+		if z.RLAH && z.includes(home) && z.includes(visited) {
+			return z, true
+		}
+	}
+	return zoneBlock{}, false
+}
+
+// domesticTariff is the tariff subscribers of ho pay at home
+func (t *SimpleChaincode) domesticTariff(stub shim.ChaincodeStubInterface, ho string) string {
+	if op, err := t.getOperator(stub, ho); err == nil {
+		return op.DomesticTariff
+	}
+	return ""
+}
+
+// fairUseExcess returns how much of volume MB, used by key in zone z in
+// the month of when, falls beyond the fair use allowance. Usage is counted
+// per zone and calendar month, and only added to the counter when record is
+// set, since a quote must not use up the allowance it prices.
+func (t *SimpleChaincode) fairUseExcess(stub shim.ChaincodeStubInterface, key string, z zoneBlock, volume float64, when time.Time, record bool) (float64, error) {
+	period := fairUsePeriod(when)
+	var c fairUseCounter
+	if err := getRecord(stub, "fairuse", []string{key, z.ID, period}, &c); err != nil {
+		c = fairUseCounter{Key: key, Zone: z.ID, Period: period}
+	}
+	before := c.DataMB
+	c.DataMB += volume
+	excess := 0.0
+	if z.FairUseMB > 0 && c.DataMB > z.FairUseMB {
+		excess = c.DataMB - z.FairUseMB
+		if before > z.FairUseMB {
+			excess = volume
+		}
+	}
+	if record {
+		if err := putRecord(stub, "fairuse", []string{key, z.ID, period}, &c); err != nil {
+			return 0, err
+		}
+	}
+	return excess, nil
+}
+
+//Set Zone: define a roaming zone and whether Roam-Like-At-Home applies in it
+//args: zone JSON e.g. {"id":"EU","countries":["ES","DE"],"rlah":true,"fairusemb":5000,"surchargepermb":0.003}
+func (t *SimpleChaincode) setZone(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	if err := callerIsAdmin(stub); err != nil {
+		return nil, err
+	}
+	if len(args) < 1 {
+		return nil, errors.New("Incorrect number of arguments. Expecting zone JSON")
+	}
+	var z zoneBlock
+	if err := json.Unmarshal([]byte(args[0]), &z); err != nil {
+		return nil, fmt.Errorf("invalid zone: %s", err)
+	}
+	if err := t.putZone(stub, z); err != nil {
+		return nil, err
+	}
+	resp := newInvokeResponse(stub, "setZone", nil)
+	resp.Result = z
+	return resp.marshal()
+}